@@ -0,0 +1,83 @@
+// Command breach-filter builds a Bloom filter from the Pwned Passwords SHA-1 dump
+// ("SHA1:COUNT" lines) for PASSWORD_BREACHED_PATH.
+// The filter is held in memory until it is written, about 1.8 bytes per hash at -fp 0.001,
+// around 1.6 GB for the full corpus. A higher -min-count or -fp makes it smaller.
+//
+//	go run ./cmd/breach-filter -in pwnedpasswords.txt -out breached.bloom -fp 0.001 -min-count 1
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/d1mitrii/authentication-service/internal/services/breach"
+)
+
+func main() {
+	in := flag.String("in", "", "Pwned Passwords SHA-1 dump")
+	out := flag.String("out", "breached.bloom", "filter file to write")
+	falsePositive := flag.Float64("fp", 0.001, "false positive rate")
+	minCount := flag.Int("min-count", 1, "skip hashes seen fewer times")
+	flag.Parse()
+	if *in == "" || *falsePositive <= 0 || *falsePositive >= 1 || *minCount < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	// the first pass counts the hashes to size the filter
+	var n uint64
+	if err := scan(file, *minCount, func([20]byte) { n++ }); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		log.Fatal(err)
+	}
+	builder := breach.NewBloomBuilder(n, *falsePositive, *minCount)
+	log.Printf("adding %d hashes, filter size %d bytes, all of it held in memory", n, builder.Size())
+	if err := scan(file, *minCount, builder.Add); err != nil {
+		log.Fatal(err)
+	}
+
+	output, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	writer := bufio.NewWriter(output)
+	if _, err := builder.WriteTo(writer); err != nil {
+		log.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if err := output.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %s", *out)
+}
+
+func scan(r io.Reader, minCount int, add func([20]byte)) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		sum, count, err := breach.ParseLine(scanner.Bytes())
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if count >= minCount {
+			add(sum)
+		}
+	}
+	return scanner.Err()
+}
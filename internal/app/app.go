@@ -13,17 +13,18 @@ import (
 	"github.com/d1mitrii/authentication-service/internal/config"
 	grpcv1 "github.com/d1mitrii/authentication-service/internal/controller/grpc/v1"
 	"github.com/d1mitrii/authentication-service/internal/controller/http/middlewares"
+	"github.com/d1mitrii/authentication-service/internal/controller/http/oauth"
 	httpv1 "github.com/d1mitrii/authentication-service/internal/controller/http/v1"
 	"github.com/d1mitrii/authentication-service/internal/metrics"
-	"github.com/d1mitrii/authentication-service/internal/repository"
-	"github.com/d1mitrii/authentication-service/internal/repository/pgdb"
-	"github.com/d1mitrii/authentication-service/internal/repository/rdb"
 	"github.com/d1mitrii/authentication-service/internal/services"
+	"github.com/d1mitrii/authentication-service/internal/services/breach"
+	"github.com/d1mitrii/authentication-service/internal/services/clients"
 	"github.com/d1mitrii/authentication-service/internal/services/jwt"
+	"github.com/d1mitrii/authentication-service/internal/services/validation"
 	"github.com/d1mitrii/authentication-service/pkg/hasher"
 	"github.com/d1mitrii/authentication-service/pkg/httpserver"
 	"github.com/d1mitrii/authentication-service/pkg/logger"
-	"github.com/d1mitrii/authentication-service/pkg/postgres"
+	"github.com/d1mitrii/authentication-service/pkg/realip"
 
 	"github.com/d1mitrii/authentication-service/internal/app/grpc"
 
@@ -31,47 +32,111 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/redis/go-redis/v9"
 )
 
 func Run(cfg *config.Config) {
 	const op = "app - Run"
 	log := logger.SetupLogger(cfg.Env)
 
-	log.Info("Connecting to postgres")
-	pg, err := postgres.New(cfg.PG.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repositories, closeStorage, err := newRepositories(ctx, log, cfg)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	defer closeStorage()
+
+	log.Info("Loading JWT signing keys")
+	keyLoader := jwt.StaticKey(cfg.JWT.Algorithm, cfg.JWT.Secret, cfg.JWT.PrivateKeyPath)
+	if cfg.JWT.KeysDir != "" {
+		keyLoader = jwt.KeyDir(cfg.JWT.Algorithm, cfg.JWT.KeysDir)
+	}
+	keyring, err := jwt.NewKeyring(keyLoader, cfg.JWT.TokenTTL)
+	if err != nil {
+		log.Error(fmt.Sprintf("%s - jwt.NewKeyring: %v", op, err))
+		return
+	}
+	log.Info("Active JWT signing key", slog.String("kid", keyring.Active().ID()))
+
+	sessionPolicy, err := services.ParseSessionLimitPolicy(cfg.Sessions.LimitPolicy)
+	if err != nil {
+		log.Error(fmt.Sprintf("%s - services.ParseSessionLimitPolicy: %v", op, err))
+		return
+	}
+
+	passwordPolicy := validation.PasswordPolicy{
+		MinLength:     cfg.Password.MinLength,
+		MaxLength:     cfg.Password.MaxLength,
+		RequireUpper:  cfg.Password.RequireUpper,
+		RequireLower:  cfg.Password.RequireLower,
+		RequireDigit:  cfg.Password.RequireDigit,
+		RequireSymbol: cfg.Password.RequireSymbol,
+		BannedWords:   cfg.Password.BannedWords,
+	}
+	if cfg.Password.BreachedPath != "" {
+		log.Info("Opening breached passwords corpus", slog.String("path", cfg.Password.BreachedPath))
+		checker, err := breach.Open(cfg.Password.BreachedPath, cfg.Password.BreachedMinCount)
+		if err != nil {
+			log.Error(fmt.Sprintf("%s - breach.Open: %v", op, err))
+			return
+		}
+		defer checker.Close()
+		passwordPolicy.Breached = checker
+	}
+
+	mail, err := newMailer(log, cfg.Mail)
 	if err != nil {
-		log.Error(fmt.Sprintf("%s - postgres.New: %v", op, err))
+		log.Error(err.Error())
 		return
 	}
-	defer pg.Close()
-
-	log.Info("Connecting to redis")
-	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", cfg.RDB.Host, cfg.RDB.Port),
-		Password: cfg.RDB.Password,
-	})
-	if err := client.Ping(context.Background()).Err(); err != nil {
-		log.Error(fmt.Sprintf("%s - redis.NewClient: %v", op, err))
+	if mail == nil && cfg.Verify.Required {
+		log.Error(fmt.Sprintf("%s: EMAIL_VERIFICATION_REQUIRED needs a MAILER to send the links", op))
 		return
 	}
-	defer client.Close()
+	verifyURL := cfg.Verify.URL
+	if verifyURL == "" {
+		verifyURL = cfg.Issuer + "/api/v1/verify-email"
+	}
+	resetURL := cfg.Reset.URL
+	if resetURL == "" {
+		// the reset endpoint of the service only takes POST, real users need a page with a form
+		if cfg.Mail.Mailer == config.MailerSMTP {
+			log.Error(fmt.Sprintf("%s: PASSWORD_RESET_URL is required with MAILER=smtp", op))
+			return
+		}
+		resetURL = cfg.Issuer + "/api/v1/password/reset"
+	}
 
 	log.Info("Initializing services")
 	service := services.New(
 		log,
 		jwt.New(
-			cfg.JWT.Secret,
+			keyring,
+			cfg.Issuer,
+			cfg.JWT.Audience,
 			cfg.JWT.TokenTTL,
 			cfg.JWT.RefreshTime,
 		),
 		hasher.New(cfg.Hasher.Salt),
-		repository.New(
-			pgdb.NewUserRepo(pg),
-			rdb.NewRefreshRepo(client, cfg.JWT.RefreshTime),
-		),
+		repositories,
+		services.SessionLimit(cfg.Sessions.MaxPerUser, sessionPolicy),
+		services.SessionTimeouts(cfg.Sessions.IdleTimeout, cfg.Sessions.MaxLifetime),
+		services.RememberMe(cfg.Sessions.RememberMe),
+		services.PasswordPolicy(passwordPolicy),
+		services.Mailer(mail),
+		services.EmailVerification(verifyURL, cfg.Verify.TTL, cfg.Verify.Required),
+		services.PasswordReset(resetURL, cfg.Reset.TTL),
 	)
 
+	oauthClients := clients.New(cfg.OAuth.Clients)
+	proxies, err := realip.New(cfg.TrustedProxies)
+	if err != nil {
+		log.Error(fmt.Sprintf("%s - realip.New: %v", op, err))
+		return
+	}
+
 	log.Info("Initializing HTTP server for metrics")
 	m := http.NewServeMux()
 	reg := prometheus.NewRegistry()
@@ -88,11 +153,12 @@ func Run(cfg *config.Config) {
 
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(middlewares.RealIP(proxies))
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middlewares.MetricsMiddleware)
 	r.Mount("/api/v1", httpv1.New(service).Routes())
+	r.Mount("/", oauth.New(service, oauthClients, cfg.Issuer).Routes())
 
 	log.Info("Starting http server...")
 	httpServer := httpserver.New(
@@ -103,7 +169,20 @@ func Run(cfg *config.Config) {
 	)
 
 	log.Info("Initializing gRPC server")
-	grpcServer := grpc.New(log, cfg.GRPC.Port, grpcv1.NewAuth(service))
+	grpcServer := grpc.New(log, cfg.GRPC.Port, grpcv1.NewAuth(service, oauthClients, proxies), service)
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+	go func() {
+		for range reload {
+			if err := keyring.Reload(); err != nil {
+				log.Error("Application JWT keys reload: ", slog.Any("err", err.Error()))
+				continue
+			}
+			log.Info("JWT signing keys reloaded", slog.String("kid", keyring.Active().ID()))
+		}
+	}()
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
@@ -142,4 +221,6 @@ func Run(cfg *config.Config) {
 	}()
 
 	wg.Wait()
+	// emails accepted before the shutdown still go out
+	service.Wait()
 }
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// expiredDeleter is a store that doesn't expire entries on its own
+type expiredDeleter interface {
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// runCleanup deletes expired entries from the stores every interval until ctx is done,
+// an interval of 0 disables the cleanup
+func runCleanup(ctx context.Context, log *slog.Logger, interval time.Duration, stores ...expiredDeleter) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, store := range stores {
+				deleted, err := store.DeleteExpired(ctx)
+				if err != nil {
+					log.Error("failed to delete expired entries", slog.String("error", err.Error()))
+					continue
+				}
+				if deleted > 0 {
+					log.Debug("expired entries deleted", slog.Int64("count", deleted))
+				}
+			}
+		}
+	}
+}
@@ -18,6 +18,14 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+// protectedMethods can't be called without an access token
+var protectedMethods = []string{
+	"/" + desc.AuthV1_ServiceDesc.ServiceName + "/ListSessions",
+	"/" + desc.AuthV1_ServiceDesc.ServiceName + "/RevokeSession",
+	"/" + desc.AuthV1_ServiceDesc.ServiceName + "/RevokeAllSessions",
+	"/" + desc.AuthV1_ServiceDesc.ServiceName + "/ChangePassword",
+}
+
 type App struct {
 	log        *slog.Logger
 	gRPCServer *grpc.Server
@@ -25,11 +33,12 @@ type App struct {
 	notify     chan error
 }
 
-func New(log *slog.Logger, port int, authService *grpcv1.Auth) *App {
+func New(log *slog.Logger, port int, authService *grpcv1.Auth, authenticator interceptors.Authenticator) *App {
+	// payloads carry passwords and tokens, only the calls and their outcome are logged
 	logOpts := []logging.Option{
 		logging.WithLogOnEvents(
-			logging.PayloadReceived,
-			logging.PayloadSent,
+			logging.StartCall,
+			logging.FinishCall,
 		),
 	}
 	recoveryOpts := []recovery.Option{
@@ -44,6 +53,7 @@ func New(log *slog.Logger, port int, authService *grpcv1.Auth) *App {
 			recovery.UnaryServerInterceptor(recoveryOpts...),
 			logging.UnaryServerInterceptor(InterceptorLogger(log), logOpts...),
 			interceptors.MetricsInterceptor,
+			interceptors.NewAuth(authenticator, protectedMethods...).Unary,
 		),
 	)
 	desc.RegisterAuthV1Server(s, authService)
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/d1mitrii/authentication-service/internal/config"
+	"github.com/d1mitrii/authentication-service/pkg/mailer"
+)
+
+// newMailer picks how emails are delivered, log and file keep them local for development.
+// none returns a nil mailer, so no email is sent.
+func newMailer(log *slog.Logger, cfg config.Mail) (mailer.Mailer, error) {
+	const op = "app - newMailer"
+	switch cfg.Mailer {
+	case config.MailerNone:
+		log.Warn("No mailer configured, verification and password reset emails are disabled")
+		return nil, nil
+	case config.MailerLog:
+		return mailer.NewLog(log), nil
+	case config.MailerFile:
+		return mailer.NewFile(cfg.FilePath, cfg.From), nil
+	case config.MailerSMTP:
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("%s: smtp host is required", op)
+		}
+		tlsMode := mailer.TLSMode(cfg.SMTPTLS)
+		switch tlsMode {
+		case mailer.TLSStartTLS, mailer.TLSImplicit, mailer.TLSNone:
+		default:
+			return nil, fmt.Errorf("%s: unknown smtp tls mode %q", op, cfg.SMTPTLS)
+		}
+		if tlsMode == mailer.TLSNone {
+			log.Warn("SMTP TLS is disabled, emails are sent in plain text")
+		}
+		return mailer.NewSMTP(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From,
+			mailer.TLS(tlsMode),
+		), nil
+	default:
+		return nil, fmt.Errorf("%s: unknown mailer %q", op, cfg.Mailer)
+	}
+}
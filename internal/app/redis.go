@@ -0,0 +1,94 @@
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/d1mitrii/authentication-service/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// newRedisClient builds the client for the configured topology.
+// Standalone connects to the first of REDIS_ADDRS or to REDIS_HOST:REDIS_PORT,
+// sentinel asks the sentinels in REDIS_ADDRS for the master named REDIS_MASTER_NAME,
+// cluster discovers the nodes starting from REDIS_ADDRS.
+func newRedisClient(cfg config.Redis) (redis.UniversalClient, error) {
+	const op = "app - newRedisClient"
+	opts := &redis.UniversalOptions{
+		Addrs:            cfg.Addrs,
+		MasterName:       cfg.MasterName,
+		DB:               cfg.DB,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		SentinelUsername: cfg.SentinelUsername,
+		SentinelPassword: cfg.SentinelPassword,
+	}
+	if len(opts.Addrs) == 0 && cfg.Host != "" {
+		opts.Addrs = []string{cfg.Host + ":" + strconv.Itoa(cfg.Port)}
+	}
+	if cfg.TLS.Enabled {
+		tlsConfig, err := redisTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", op, err)
+		}
+		opts.TLSConfig = tlsConfig
+	}
+
+	switch cfg.Mode {
+	case config.RedisStandalone:
+		if len(opts.Addrs) == 0 {
+			return nil, fmt.Errorf("%s: redis host is required", op)
+		}
+		return redis.NewClient(opts.Simple()), nil
+	case config.RedisSentinel:
+		if cfg.MasterName == "" || len(cfg.Addrs) == 0 {
+			return nil, fmt.Errorf("%s: sentinel mode requires master name and sentinel addresses", op)
+		}
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case config.RedisCluster:
+		if len(opts.Addrs) == 0 {
+			return nil, fmt.Errorf("%s: cluster mode requires node addresses", op)
+		}
+		if cfg.DB != 0 {
+			return nil, fmt.Errorf("%s: redis cluster supports only database 0", op)
+		}
+		// keys of one user share the hash tag {<user id>}, braces in the prefix would replace it
+		if strings.ContainsAny(cfg.KeyPrefix, "{}") {
+			return nil, fmt.Errorf("%s: redis key prefix can't contain braces in cluster mode", op)
+		}
+		return redis.NewClusterClient(opts.Cluster()), nil
+	default:
+		return nil, fmt.Errorf("%s: unknown redis mode %q", op, cfg.Mode)
+	}
+}
+
+func redisTLSConfig(cfg config.RedisTLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.ServerName,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("os.ReadFile: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls.LoadX509KeyPair: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
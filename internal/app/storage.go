@@ -0,0 +1,145 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/d1mitrii/authentication-service/internal/config"
+	"github.com/d1mitrii/authentication-service/internal/repository"
+	"github.com/d1mitrii/authentication-service/internal/repository/cache"
+	"github.com/d1mitrii/authentication-service/internal/repository/memory"
+	"github.com/d1mitrii/authentication-service/internal/repository/pgdb"
+	"github.com/d1mitrii/authentication-service/internal/repository/rdb"
+	"github.com/d1mitrii/authentication-service/internal/repository/sqlitedb"
+	"github.com/d1mitrii/authentication-service/pkg/postgres"
+	"github.com/d1mitrii/authentication-service/pkg/sqlite"
+)
+
+// newRepositories connects the stores picked by configuration,
+// the returned function closes the connections
+func newRepositories(ctx context.Context, log *slog.Logger, cfg *config.Config) (*repository.Repositories, func(), error) {
+	const op = "app - newRepositories"
+	var closers []func()
+	closeAll := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	var pg *postgres.Postgres
+	var db *sqlite.SQLite
+	var users repository.UserRepo
+	var tokens repository.OneTimeTokenRepo
+	switch cfg.Storage.Type {
+	case config.StoragePostgres:
+		if cfg.Storage.URL == "" {
+			return nil, nil, fmt.Errorf("%s: postgres url is required", op)
+		}
+		log.Info("Connecting to postgres")
+		var err error
+		pg, err = postgres.New(cfg.Storage.URL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s - postgres.New: %v", op, err)
+		}
+		closers = append(closers, pg.Close)
+		users = pgdb.NewUserRepo(pg)
+		pgTokens := pgdb.NewOneTimeTokenRepo(pg)
+		go runCleanup(ctx, log, cfg.Sessions.CleanupInterval, pgTokens)
+		tokens = pgTokens
+	case config.StorageSQLite:
+		log.Info("Opening sqlite database", slog.String("path", cfg.Storage.Path))
+		var err error
+		db, err = sqlite.New(cfg.Storage.Path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s - sqlite.New: %v", op, err)
+		}
+		closers = append(closers, db.Close)
+		if err := sqlitedb.Migrate(ctx, db); err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("%s - sqlitedb.Migrate: %v", op, err)
+		}
+		users = sqlitedb.NewUserRepo(db)
+		sqliteTokens := sqlitedb.NewOneTimeTokenRepo(db)
+		go runCleanup(ctx, log, cfg.Sessions.CleanupInterval, sqliteTokens)
+		tokens = sqliteTokens
+	case config.StorageMemory:
+		log.Warn("Using in-memory storage, all data is lost on restart")
+		users = memory.NewUserRepo()
+		tokens = memory.NewOneTimeTokenRepo()
+	default:
+		return nil, nil, fmt.Errorf("%s: unknown storage type %q", op, cfg.Storage.Type)
+	}
+
+	// a session may stay unused for the idle timeout, or the remember me TTL when that is longer
+	maxSessionIdle := cfg.JWT.RefreshTime
+	if cfg.Sessions.IdleTimeout > 0 {
+		maxSessionIdle = cfg.Sessions.IdleTimeout
+	}
+	maxSessionIdle = max(maxSessionIdle, cfg.Sessions.RememberMe)
+
+	// sessions stay next to the users unless that is postgres, where redis is the default
+	sessionStorage := cfg.Sessions.Storage
+	if sessionStorage == "" {
+		sessionStorage = cfg.Storage.Type
+		if sessionStorage == config.StoragePostgres {
+			sessionStorage = config.StorageRedis
+		}
+	}
+
+	var sessions repository.RefreshSessionRepo
+	var denylist repository.TokenDenylistRepo
+	switch sessionStorage {
+	case config.StorageRedis:
+		log.Info("Connecting to redis", slog.String("mode", cfg.RDB.Mode))
+		client, err := newRedisClient(cfg.RDB)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("%s - %v", op, err)
+		}
+		closers = append(closers, func() { client.Close() })
+		if err := client.Ping(ctx).Err(); err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("%s - client.Ping: %v", op, err)
+		}
+		sessions = rdb.NewRefreshRepo(client, cfg.RDB.KeyPrefix, maxSessionIdle)
+		denylist = rdb.NewTokenDenylist(client, cfg.RDB.KeyPrefix)
+	case config.StoragePostgres:
+		if pg == nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("%s: postgres session storage requires postgres storage", op)
+		}
+		pgSessions := pgdb.NewRefreshSessionRepo(pg, maxSessionIdle)
+		pgDenylist := pgdb.NewTokenDenylist(pg)
+		go runCleanup(ctx, log, cfg.Sessions.CleanupInterval, pgSessions, pgDenylist)
+		sessions = pgSessions
+		denylist = pgDenylist
+	case config.StorageSQLite:
+		if db == nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("%s: sqlite session storage requires sqlite storage", op)
+		}
+		sqliteSessions := sqlitedb.NewRefreshSessionRepo(db, maxSessionIdle)
+		sqliteDenylist := sqlitedb.NewTokenDenylist(db)
+		go runCleanup(ctx, log, cfg.Sessions.CleanupInterval, sqliteSessions, sqliteDenylist)
+		sessions = sqliteSessions
+		denylist = sqliteDenylist
+	case config.StorageMemory:
+		sessions = memory.NewRefreshRepo(maxSessionIdle)
+		denylist = memory.NewTokenDenylist()
+	default:
+		closeAll()
+		return nil, nil, fmt.Errorf("%s: unknown session storage %q", op, sessionStorage)
+	}
+	log.Info("Storage", slog.String("users", cfg.Storage.Type), slog.String("sessions", sessionStorage))
+	if cfg.Sessions.CleanupInterval <= 0 && (pg != nil || db != nil) {
+		log.Warn("Cleanup of expired rows is disabled")
+	}
+
+	return repository.New(
+		users,
+		tokens,
+		sessions,
+		cache.NewTokenDenylist(denylist, cfg.JWT.DenylistCacheTTL),
+	), closeAll, nil
+}
@@ -10,13 +10,23 @@ import (
 
 type Config struct {
 	Env        string     `yaml:"env" env:"ENV" env-required:"true"`
+	Issuer     string     `yaml:"issuer" env:"ISSUER_URL" env-default:"http://localhost:8080"`
 	JWT        JWT        `yaml:"jwt"`
-	PG         Postgres   `yaml:"storage"`
+	Sessions   Sessions   `yaml:"sessions"`
+	Storage    Storage    `yaml:"storage"`
 	RDB        Redis      `yaml:"redis"`
 	HTTP       HTTPServer `yaml:"http"`
 	GRPC       GRPC       `yaml:"grpc"`
 	Prometheus Prometheus `yaml:"prometheus"`
 	Hasher     Hasher     `yaml:"hasher"`
+	Password   Password   `yaml:"password"`
+	Mail       Mail       `yaml:"mail"`
+	Verify     Verify     `yaml:"email_verification"`
+	Reset      Reset      `yaml:"password_reset"`
+	OAuth      OAuth      `yaml:"oauth"`
+
+	// Addresses or CIDR ranges of reverse proxies whose X-Real-IP and X-Forwarded-For are believed
+	TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
 }
 
 type HTTPServer struct {
@@ -32,26 +42,126 @@ type Prometheus struct {
 	Port int `yaml:"port" env:"PROMETHEUS_HTTP_PORT" env-required:"true"`
 }
 
-type Postgres struct {
-	URL string `yaml:"url" env:"POSTGRES_URL" env-required:"true"`
+const (
+	StoragePostgres = "postgres"
+	StorageRedis    = "redis"
+	StorageSQLite   = "sqlite"
+	StorageMemory   = "memory"
+)
+
+type Storage struct {
+	Type string `yaml:"type" env:"STORAGE_TYPE" env-default:"postgres"`
+	URL  string `yaml:"url" env:"POSTGRES_URL"`
+	Path string `yaml:"path" env:"SQLITE_PATH" env-default:"auth.db"`
 }
 
+const (
+	RedisStandalone = "standalone"
+	RedisSentinel   = "sentinel"
+	RedisCluster    = "cluster"
+)
+
 type Redis struct {
-	Host     string `yaml:"host" env:"REDIS_HOST" env-required:"true"`
-	Port     int    `yaml:"port" env:"REDIS_PORT" env-required:"true"`
-	Password string `yaml:"password" env:"REDIS_PASSWORD"`
+	Mode             string   `yaml:"mode" env:"REDIS_MODE" env-default:"standalone"`
+	Host             string   `yaml:"host" env:"REDIS_HOST"`
+	Port             int      `yaml:"port" env:"REDIS_PORT" env-default:"6379"`
+	Addrs            []string `yaml:"addrs" env:"REDIS_ADDRS"`
+	MasterName       string   `yaml:"master_name" env:"REDIS_MASTER_NAME"`
+	DB               int      `yaml:"db" env:"REDIS_DB" env-default:"0"`
+	Username         string   `yaml:"username" env:"REDIS_USERNAME"`
+	Password         string   `yaml:"password" env:"REDIS_PASSWORD"`
+	SentinelUsername string   `yaml:"sentinel_username" env:"REDIS_SENTINEL_USERNAME"`
+	SentinelPassword string   `yaml:"sentinel_password" env:"REDIS_SENTINEL_PASSWORD"`
+	KeyPrefix        string   `yaml:"key_prefix" env:"REDIS_KEY_PREFIX"`
+	TLS              RedisTLS `yaml:"tls"`
+}
+
+type RedisTLS struct {
+	Enabled    bool   `yaml:"enabled" env:"REDIS_TLS" env-default:"false"`
+	CAFile     string `yaml:"ca_file" env:"REDIS_TLS_CA_FILE"`
+	CertFile   string `yaml:"cert_file" env:"REDIS_TLS_CERT_FILE"`
+	KeyFile    string `yaml:"key_file" env:"REDIS_TLS_KEY_FILE"`
+	ServerName string `yaml:"server_name" env:"REDIS_TLS_SERVER_NAME"`
 }
 
 type JWT struct {
-	Secret      string        `yaml:"secret_key" env:"JWT_SECRET" env-required:"true"`
-	TokenTTL    time.Duration `yaml:"token_ttl" env:"JWT_TOKEN_TTL" env-required:"true"`
-	RefreshTime time.Duration `yaml:"refresh_time" env:"JWT_REFRESH" env-required:"true"`
+	Algorithm        string        `yaml:"algorithm" env:"JWT_ALGORITHM" env-default:"HS256"`
+	Secret           string        `yaml:"secret_key" env:"JWT_SECRET"`
+	PrivateKeyPath   string        `yaml:"private_key_path" env:"JWT_PRIVATE_KEY_PATH"`
+	KeysDir          string        `yaml:"keys_dir" env:"JWT_KEYS_DIR"`
+	Audience         []string      `yaml:"audience" env:"JWT_AUDIENCE" env-default:"authentication-service"`
+	TokenTTL         time.Duration `yaml:"token_ttl" env:"JWT_TOKEN_TTL" env-required:"true"`
+	RefreshTime      time.Duration `yaml:"refresh_time" env:"JWT_REFRESH" env-required:"true"`
+	DenylistCacheTTL time.Duration `yaml:"denylist_cache_ttl" env:"JWT_DENYLIST_CACHE_TTL" env-default:"5s"`
+}
+
+// Sessions configures session limits. MaxPerUser is best effort,
+// concurrent logins of one user may exceed it until the next login.
+type Sessions struct {
+	Storage         string        `yaml:"storage" env:"SESSION_STORAGE"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env:"SESSION_CLEANUP_INTERVAL" env-default:"10m"`
+	MaxPerUser      int           `yaml:"max_sessions_per_user" env:"MAX_SESSIONS_PER_USER" env-default:"0"`
+	LimitPolicy     string        `yaml:"limit_policy" env:"SESSION_LIMIT_POLICY" env-default:"reject"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" env:"SESSION_IDLE_TIMEOUT"`
+	MaxLifetime     time.Duration `yaml:"max_lifetime" env:"SESSION_MAX_LIFETIME"`
+	RememberMe      time.Duration `yaml:"remember_me_ttl" env:"SESSION_REMEMBER_ME_TTL" env-default:"720h"`
+}
+
+type OAuth struct {
+	// Client id to client secret, e.g. "gateway:secret,billing:secret"
+	Clients map[string]string `yaml:"clients" env:"OAUTH_CLIENTS"`
 }
 
 type Hasher struct {
 	Salt int `yaml:"salt" env:"HASH_SALT" env-default:"10"`
 }
 
+type Password struct {
+	MinLength     int      `yaml:"min_length" env:"PASSWORD_MIN_LENGTH" env-default:"8"`
+	MaxLength     int      `yaml:"max_length" env:"PASSWORD_MAX_LENGTH" env-default:"72"`
+	RequireUpper  bool     `yaml:"require_upper" env:"PASSWORD_REQUIRE_UPPER" env-default:"false"`
+	RequireLower  bool     `yaml:"require_lower" env:"PASSWORD_REQUIRE_LOWER" env-default:"false"`
+	RequireDigit  bool     `yaml:"require_digit" env:"PASSWORD_REQUIRE_DIGIT" env-default:"false"`
+	RequireSymbol bool     `yaml:"require_symbol" env:"PASSWORD_REQUIRE_SYMBOL" env-default:"false"`
+	BannedWords   []string `yaml:"banned_words" env:"PASSWORD_BANNED_WORDS"`
+
+	BreachedPath     string `yaml:"breached_path" env:"PASSWORD_BREACHED_PATH"`
+	BreachedMinCount int    `yaml:"breached_min_count" env:"PASSWORD_BREACHED_MIN_COUNT" env-default:"1"`
+}
+
+const (
+	MailerNone = "none"
+	MailerLog  = "log"
+	MailerFile = "file"
+	MailerSMTP = "smtp"
+)
+
+type Mail struct {
+	Mailer       string `yaml:"mailer" env:"MAILER" env-default:"none"`
+	From         string `yaml:"from" env:"MAIL_FROM" env-default:"no-reply@localhost"`
+	FilePath     string `yaml:"file_path" env:"MAIL_FILE_PATH" env-default:"mail.txt"`
+	SMTPHost     string `yaml:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort     int    `yaml:"smtp_port" env:"SMTP_PORT" env-default:"587"`
+	SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD"`
+	// starttls, implicit (usually port 465) or none
+	SMTPTLS string `yaml:"smtp_tls" env:"SMTP_TLS" env-default:"starttls"`
+}
+
+type Verify struct {
+	Required bool          `yaml:"required" env:"EMAIL_VERIFICATION_REQUIRED" env-default:"false"`
+	TTL      time.Duration `yaml:"ttl" env:"EMAIL_VERIFICATION_TTL" env-default:"24h"`
+	// Page the token is appended to, defaults to the verify endpoint of the service.
+	// It should let the user confirm before posting the token, mail scanners open links too.
+	URL string `yaml:"url" env:"EMAIL_VERIFICATION_URL"`
+}
+
+type Reset struct {
+	TTL time.Duration `yaml:"ttl" env:"PASSWORD_RESET_TTL" env-default:"30m"`
+	// Page the token is appended to, it should ask for the new password and post both to the reset endpoint
+	URL string `yaml:"url" env:"PASSWORD_RESET_URL"`
+}
+
 func MustLoad() *Config {
 	var cfg Config
 	path := fetchConfigPath()
@@ -0,0 +1,80 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/services"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (models.Claims, error)
+}
+
+type ctxClaims struct{}
+
+// Auth verifies bearer access tokens from the "authorization" metadata of protected methods.
+// Other methods ignore the metadata, so a stale token left in a client doesn't break
+// the calls that replace it, like Login and Refresh.
+type Auth struct {
+	auth      Authenticator
+	protected map[string]struct{}
+}
+
+func NewAuth(auth Authenticator, protected ...string) *Auth {
+	a := &Auth{
+		auth:      auth,
+		protected: make(map[string]struct{}, len(protected)),
+	}
+	for _, method := range protected {
+		a.protected[method] = struct{}{}
+	}
+	return a
+}
+
+func (a *Auth) Unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if _, protected := a.protected[info.FullMethod]; !protected {
+		return handler(ctx, req)
+	}
+	token, ok := bearerToken(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "access token required")
+	}
+
+	claims, err := a.auth.Authenticate(ctx, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, "internal server error")
+		}
+	}
+	return handler(context.WithValue(ctx, ctxClaims{}, claims), req)
+}
+
+// ClaimsFromContext returns the claims of the access token verified by Auth
+func ClaimsFromContext(ctx context.Context) (models.Claims, bool) {
+	claims, ok := ctx.Value(ctxClaims{}).(models.Claims)
+	return claims, ok
+}
+
+func bearerToken(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	for _, value := range md.Get("authorization") {
+		if token, ok := strings.CutPrefix(value, "Bearer "); ok && len(token) != 0 {
+			return token, true
+		}
+	}
+	return "", false
+}
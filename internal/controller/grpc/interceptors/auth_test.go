@@ -0,0 +1,69 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/services"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type fakeAuthenticator map[string]error
+
+func (f fakeAuthenticator) Authenticate(ctx context.Context, token string) (models.Claims, error) {
+	if err := f[token]; err != nil {
+		return models.Claims{}, err
+	}
+	return models.Claims{UserId: 1}, nil
+}
+
+func TestAuth(t *testing.T) {
+	auth := NewAuth(fakeAuthenticator{
+		"revoked": services.ErrTokenRevoked,
+		"down":    errors.New("denylist unavailable"),
+	}, "/auth_v1.AuthV1/ListSessions")
+
+	tests := []struct {
+		name   string
+		method string
+		header string
+		code   codes.Code
+		claims bool
+	}{
+		{name: "protected", method: "/auth_v1.AuthV1/ListSessions", header: "Bearer good", code: codes.OK, claims: true},
+		{name: "no token", method: "/auth_v1.AuthV1/ListSessions", code: codes.Unauthenticated},
+		{name: "basic credentials", method: "/auth_v1.AuthV1/ListSessions", header: "Basic YXBpOnNlY3JldA==", code: codes.Unauthenticated},
+		{name: "revoked token", method: "/auth_v1.AuthV1/ListSessions", header: "Bearer revoked", code: codes.Unauthenticated},
+		{name: "authenticator failing", method: "/auth_v1.AuthV1/ListSessions", header: "Bearer down", code: codes.Internal},
+		{name: "unprotected without token", method: "/auth_v1.AuthV1/Login", code: codes.OK},
+		{name: "unprotected with stale token", method: "/auth_v1.AuthV1/Refresh", header: "Bearer revoked", code: codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.header != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tt.header))
+			}
+			called := false
+			_, err := auth.Unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, req any) (any, error) {
+				called = true
+				if _, ok := ClaimsFromContext(ctx); ok != tt.claims {
+					t.Errorf("ClaimsFromContext() ok = %v, want %v", ok, tt.claims)
+				}
+				return nil, nil
+			})
+			if code := status.Code(err); code != tt.code {
+				t.Errorf("Unary() code = %v, want %v", code, tt.code)
+			}
+			if called != (tt.code == codes.OK) {
+				t.Errorf("handler called = %v", called)
+			}
+		})
+	}
+}
@@ -2,40 +2,70 @@ package v1
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"github.com/d1mitrii/authentication-service/internal/converter"
 	"github.com/d1mitrii/authentication-service/internal/models"
 	"github.com/d1mitrii/authentication-service/internal/services"
+	"github.com/d1mitrii/authentication-service/internal/services/validation"
 	desc "github.com/d1mitrii/authentication-service/pkg/auth/v1"
+	"github.com/d1mitrii/authentication-service/pkg/realip"
+	"strings"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
 type AuthService interface {
 	Register(context.Context, models.User) (int, error)
-	Login(context.Context, models.User) (models.Token, error)
-	RefreshSession(context.Context, string) (models.Token, error)
-	Logout(context.Context, string) error
+	Login(context.Context, models.User, models.ClientInfo, bool) (models.Token, error)
+	RefreshSession(context.Context, string, models.ClientInfo) (models.Token, error)
+	Logout(context.Context, string, string) error
+	JWKS() models.JWKSet
+	Introspect(context.Context, string, string) (models.Introspection, error)
+	Revoke(context.Context, string) error
+	Authenticate(context.Context, string) (models.Claims, error)
+	ListSessions(context.Context, int) ([]models.RefreshSession, error)
+	RevokeSession(context.Context, int, string) error
+	RevokeAllSessions(context.Context, int) error
+	VerifyEmail(context.Context, string) error
+	ResendVerification(context.Context, string) error
+	RequestPasswordReset(context.Context, string) error
+	ResetPassword(ctx context.Context, token string, password string) error
+	ChangePassword(ctx context.Context, userId int, currentPassword, newPassword string, keepRefreshToken string) error
+}
+
+const maxBatchValidate = 100
+
+type ClientAuthenticator interface {
+	Authenticate(id, secret string) bool
 }
 
 type Auth struct {
 	desc.UnimplementedAuthV1Server
 	service AuthService
+	clients ClientAuthenticator
+	proxies *realip.Proxies
 }
 
-func NewAuth(service AuthService) *Auth {
+func NewAuth(service AuthService, clients ClientAuthenticator, proxies *realip.Proxies) *Auth {
 	return &Auth{
 		service: service,
+		clients: clients,
+		proxies: proxies,
 	}
 }
 
 func (a *Auth) Register(ctx context.Context, req *desc.RegisterRequest) (*desc.RegisterResponse, error) {
-	user, err := converter.RegisterReqToUserModel(req)
-	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
-	}
-	userId, err := a.service.Register(ctx, user)
+	userId, err := a.service.Register(ctx, converter.RegisterReqToUserModel(req))
 	if err != nil {
+		var invalid *validation.Error
+		if errors.As(err, &invalid) {
+			return nil, validationError(invalid)
+		}
 		switch err {
 		case services.ErrHashing:
 			return nil, status.Error(codes.Canceled, err.Error())
@@ -53,13 +83,17 @@ func (a *Auth) Login(ctx context.Context, req *desc.LoginRequest) (*desc.Token,
 	if err != nil {
 		return &desc.Token{}, status.Error(codes.InvalidArgument, err.Error())
 	}
-	token, err := a.service.Login(ctx, user)
+	token, err := a.service.Login(ctx, user, a.clientInfo(ctx), req.RememberMe)
 	if err != nil {
 		switch err {
 		case services.ErrIncorrectPassword:
 			return &desc.Token{}, status.Error(codes.InvalidArgument, err.Error())
 		case services.ErrUserNotFound:
 			return &desc.Token{}, status.Error(codes.NotFound, err.Error())
+		case services.ErrSessionLimit:
+			return &desc.Token{}, status.Error(codes.ResourceExhausted, err.Error())
+		case services.ErrEmailNotVerified:
+			return &desc.Token{}, status.Error(codes.FailedPrecondition, err.Error())
 		default:
 			return nil, status.Error(codes.Aborted, "internal server error")
 		}
@@ -74,8 +108,11 @@ func (a *Auth) Refresh(ctx context.Context, req *desc.RefreshRequest) (*desc.Tok
 	if len(req.RefreshToken) == 0 {
 		return &desc.Token{}, status.Error(codes.InvalidArgument, "empty refresh token provided")
 	}
-	token, err := a.service.RefreshSession(ctx, req.RefreshToken)
+	token, err := a.service.RefreshSession(ctx, req.RefreshToken, a.clientInfo(ctx))
 	if err != nil {
+		if err == services.ErrSessionExpired {
+			return &desc.Token{}, status.Error(codes.Unauthenticated, err.Error())
+		}
 		return &desc.Token{}, status.Error(codes.NotFound, "refresh session not found")
 	}
 	return &desc.Token{
@@ -88,7 +125,7 @@ func (a *Auth) Logout(ctx context.Context, req *desc.LogoutRequest) (*desc.Logou
 	if len(req.RefreshToken) == 0 {
 		return &desc.LogoutResponse{}, status.Error(codes.InvalidArgument, "empty refresh token provided")
 	}
-	err := a.service.Logout(ctx, req.RefreshToken)
+	err := a.service.Logout(ctx, req.RefreshToken, req.AccessToken)
 	if err != nil {
 		return &desc.LogoutResponse{}, status.Error(codes.NotFound, "refresh session not found")
 	}
@@ -96,3 +133,145 @@ func (a *Auth) Logout(ctx context.Context, req *desc.LogoutRequest) (*desc.Logou
 		Success: true,
 	}, nil
 }
+
+func (a *Auth) GetJWKS(ctx context.Context, req *desc.GetJWKSRequest) (*desc.JWKS, error) {
+	return converter.JWKSToResponse(a.service.JWKS()), nil
+}
+
+func (a *Auth) Introspect(ctx context.Context, req *desc.IntrospectRequest) (*desc.IntrospectResponse, error) {
+	if err := a.authenticateClient(ctx); err != nil {
+		return nil, err
+	}
+	if len(req.Token) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty token provided")
+	}
+	result, err := a.service.Introspect(ctx, req.Token, req.TokenTypeHint)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	return converter.IntrospectionToResponse(result), nil
+}
+
+func (a *Auth) Revoke(ctx context.Context, req *desc.RevokeRequest) (*desc.RevokeResponse, error) {
+	if len(req.Token) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty token provided")
+	}
+	if err := a.service.Revoke(ctx, req.Token); err != nil {
+		return nil, status.Error(codes.Unavailable, "service unavailable")
+	}
+	return &desc.RevokeResponse{}, nil
+}
+
+func (a *Auth) ValidateToken(ctx context.Context, req *desc.ValidateTokenRequest) (*desc.ValidateTokenResponse, error) {
+	if err := a.authenticateClient(ctx); err != nil {
+		return nil, err
+	}
+	if len(req.AccessToken) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty access token provided")
+	}
+	result, err := a.validate(ctx, req.AccessToken)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	return result, nil
+}
+
+func (a *Auth) BatchValidateToken(ctx context.Context, req *desc.BatchValidateTokenRequest) (*desc.BatchValidateTokenResponse, error) {
+	if err := a.authenticateClient(ctx); err != nil {
+		return nil, err
+	}
+	if len(req.AccessTokens) > maxBatchValidate {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d tokens per batch", maxBatchValidate)
+	}
+	results := make([]*desc.ValidateTokenResponse, 0, len(req.AccessTokens))
+	for _, token := range req.AccessTokens {
+		result, err := a.validate(ctx, token)
+		if err != nil {
+			result = &desc.ValidateTokenResponse{Reason: desc.TokenRejectionReason_TOKEN_REJECTION_REASON_UNAVAILABLE}
+		}
+		results = append(results, result)
+	}
+	return &desc.BatchValidateTokenResponse{Results: results}, nil
+}
+
+// validate answers with the rejection reason of an invalid token, other errors are returned
+func (a *Auth) validate(ctx context.Context, token string) (*desc.ValidateTokenResponse, error) {
+	claims, err := a.service.Authenticate(ctx, token)
+	if err != nil {
+		if !errors.Is(err, services.ErrInvalidToken) {
+			return nil, err
+		}
+		return &desc.ValidateTokenResponse{Reason: converter.RejectionReason(err)}, nil
+	}
+	return converter.ClaimsToValidateResponse(claims), nil
+}
+
+// authenticateClient checks the client credentials of service-to-service calls
+func (a *Auth) authenticateClient(ctx context.Context) error {
+	id, secret, ok := clientCredentials(ctx)
+	if !ok || !a.clients.Authenticate(id, secret) {
+		return status.Error(codes.Unauthenticated, "client authentication failed")
+	}
+	return nil
+}
+
+// clientInfo describes the caller from the peer address and metadata.
+// Like on the HTTP side, x-real-ip and x-forwarded-for count only when the peer is a trusted proxy.
+func (a *Auth) clientInfo(ctx context.Context) models.ClientInfo {
+	var peerAddr string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		peerAddr = p.Addr.String()
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	return models.ClientInfo{
+		IP:         a.proxies.ClientIP(peerAddr, firstValue(md, "x-real-ip"), md.Get("x-forwarded-for")),
+		UserAgent:  firstValue(md, "user-agent"),
+		DeviceName: firstValue(md, "x-device-name"),
+	}
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) != 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// clientCredentials reads "authorization: Basic base64(id:secret)" metadata
+func clientCredentials(ctx context.Context) (string, string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", "", false
+	}
+	for _, value := range md.Get("authorization") {
+		encoded, ok := strings.CutPrefix(value, "Basic ")
+		if !ok {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", "", false
+		}
+		id, secret, ok := strings.Cut(string(decoded), ":")
+		return id, secret, ok
+	}
+	return "", "", false
+}
+
+// validationError returns InvalidArgument with a BadRequest detail listing every violated rule,
+// descriptions start with the rule name
+func validationError(err *validation.Error) error {
+	violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(err.Violations))
+	for _, v := range err.Violations {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Rule + ": " + v.Message,
+		})
+	}
+	st, detailsErr := status.New(codes.InvalidArgument, "validation failed").
+		WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if detailsErr != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return st.Err()
+}
@@ -0,0 +1,289 @@
+package v1
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/controller/grpc/interceptors"
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/services"
+	"github.com/d1mitrii/authentication-service/internal/services/clients"
+	"github.com/d1mitrii/authentication-service/internal/services/validation"
+	desc "github.com/d1mitrii/authentication-service/pkg/auth/v1"
+	"github.com/d1mitrii/authentication-service/pkg/realip"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// fakeService answers the calls the tests make, the others panic on the nil interface
+type fakeService struct {
+	AuthService
+	claims   map[string]models.Claims
+	rejected map[string]error
+	login    func(models.User, models.ClientInfo) (models.Token, error)
+	register func(models.User) (int, error)
+}
+
+func (f *fakeService) Authenticate(ctx context.Context, token string) (models.Claims, error) {
+	if claims, ok := f.claims[token]; ok {
+		return claims, nil
+	}
+	if err, ok := f.rejected[token]; ok {
+		return models.Claims{}, err
+	}
+	return models.Claims{}, errors.New("denylist unavailable")
+}
+
+func (f *fakeService) Login(ctx context.Context, user models.User, client models.ClientInfo, rememberMe bool) (models.Token, error) {
+	return f.login(user, client)
+}
+
+func (f *fakeService) Register(ctx context.Context, user models.User) (int, error) {
+	return f.register(user)
+}
+
+func newTestAuth(t *testing.T, service AuthService, trusted ...string) *Auth {
+	t.Helper()
+	proxies, err := realip.New(trusted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAuth(service, clients.New(map[string]string{"api": "secret"}), proxies)
+}
+
+func withClient(ctx context.Context, id, secret string) context.Context {
+	credentials := base64.StdEncoding.EncodeToString([]byte(id + ":" + secret))
+	return metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Basic "+credentials))
+}
+
+func TestValidateTokenClientAuth(t *testing.T) {
+	a := newTestAuth(t, &fakeService{claims: map[string]models.Claims{"good": {UserId: 1}}})
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		code codes.Code
+	}{
+		{name: "no credentials", ctx: ctx, code: codes.Unauthenticated},
+		{name: "wrong secret", ctx: withClient(ctx, "api", "wrong"), code: codes.Unauthenticated},
+		{name: "unknown client", ctx: withClient(ctx, "web", "secret"), code: codes.Unauthenticated},
+		{name: "bearer token", ctx: metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer good")), code: codes.Unauthenticated},
+		{name: "ok", ctx: withClient(ctx, "api", "secret"), code: codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.ValidateToken(tt.ctx, &desc.ValidateTokenRequest{AccessToken: "good"})
+			if code := status.Code(err); code != tt.code {
+				t.Errorf("ValidateToken() code = %v, want %v", code, tt.code)
+			}
+			_, err = a.BatchValidateToken(tt.ctx, &desc.BatchValidateTokenRequest{AccessTokens: []string{"good"}})
+			if code := status.Code(err); code != tt.code {
+				t.Errorf("BatchValidateToken() code = %v, want %v", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestValidateToken(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	a := newTestAuth(t, &fakeService{
+		claims:   map[string]models.Claims{"good": {UserId: 1, Email: "ann@example.com", ExpiresAt: expiresAt, ID: "jti"}},
+		rejected: map[string]error{"old": services.ErrTokenExpired},
+	})
+	ctx := withClient(context.Background(), "api", "secret")
+
+	result, err := a.ValidateToken(ctx, &desc.ValidateTokenRequest{AccessToken: "good"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid || result.UserId != 1 || result.ExpiresAt != expiresAt.Unix() || result.TokenId != "jti" {
+		t.Errorf("ValidateToken() = %v", result)
+	}
+	result, err = a.ValidateToken(ctx, &desc.ValidateTokenRequest{AccessToken: "old"})
+	if err != nil || result.Valid || result.Reason != desc.TokenRejectionReason_TOKEN_REJECTION_REASON_EXPIRED {
+		t.Errorf("ValidateToken() of an expired token = %v, %v", result, err)
+	}
+	if _, err := a.ValidateToken(ctx, &desc.ValidateTokenRequest{AccessToken: "unknown"}); status.Code(err) != codes.Internal {
+		t.Errorf("ValidateToken() with the service failing error = %v, want Internal", err)
+	}
+	if _, err := a.ValidateToken(ctx, &desc.ValidateTokenRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ValidateToken() of an empty token error = %v, want InvalidArgument", err)
+	}
+}
+
+func TestBatchValidateToken(t *testing.T) {
+	a := newTestAuth(t, &fakeService{
+		claims:   map[string]models.Claims{"good": {UserId: 1}},
+		rejected: map[string]error{"revoked": services.ErrTokenRevoked},
+	})
+	ctx := withClient(context.Background(), "api", "secret")
+
+	response, err := a.BatchValidateToken(ctx, &desc.BatchValidateTokenRequest{AccessTokens: []string{"good", "revoked", "unknown"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []desc.TokenRejectionReason{
+		desc.TokenRejectionReason_TOKEN_REJECTION_REASON_UNSPECIFIED,
+		desc.TokenRejectionReason_TOKEN_REJECTION_REASON_REVOKED,
+		desc.TokenRejectionReason_TOKEN_REJECTION_REASON_UNAVAILABLE,
+	}
+	if len(response.Results) != len(want) {
+		t.Fatalf("BatchValidateToken() = %d results, want %d", len(response.Results), len(want))
+	}
+	for i, result := range response.Results {
+		if result.Reason != want[i] || result.Valid != (i == 0) {
+			t.Errorf("result %d = %v, want reason %v", i, result, want[i])
+		}
+	}
+
+	tokens := make([]string, maxBatchValidate+1)
+	if _, err := a.BatchValidateToken(ctx, &desc.BatchValidateTokenRequest{AccessTokens: tokens}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("BatchValidateToken() of %d tokens error = %v, want InvalidArgument", len(tokens), err)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *desc.LoginRequest
+		err  error
+		code codes.Code
+	}{
+		{name: "ok", req: &desc.LoginRequest{Email: "ann@example.com", Password: "pass"}, code: codes.OK},
+		{name: "empty password", req: &desc.LoginRequest{Email: "ann@example.com"}, code: codes.InvalidArgument},
+		{name: "wrong password", req: &desc.LoginRequest{Email: "ann@example.com", Password: "pass"}, err: services.ErrIncorrectPassword, code: codes.InvalidArgument},
+		{name: "unknown user", req: &desc.LoginRequest{Email: "ann@example.com", Password: "pass"}, err: services.ErrUserNotFound, code: codes.NotFound},
+		{name: "session limit", req: &desc.LoginRequest{Email: "ann@example.com", Password: "pass"}, err: services.ErrSessionLimit, code: codes.ResourceExhausted},
+		{name: "not verified", req: &desc.LoginRequest{Email: "ann@example.com", Password: "pass"}, err: services.ErrEmailNotVerified, code: codes.FailedPrecondition},
+		{name: "internal", req: &desc.LoginRequest{Email: "ann@example.com", Password: "pass"}, err: services.ErrSessionCreateFail, code: codes.Aborted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuth(t, &fakeService{login: func(models.User, models.ClientInfo) (models.Token, error) {
+				return models.Token{Access: "access", Refresh: "refresh"}, tt.err
+			}})
+			token, err := a.Login(context.Background(), tt.req)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("Login() code = %v, want %v", code, tt.code)
+			}
+			if err == nil && (token.AccessToken != "access" || token.RefreshToken != "refresh") {
+				t.Errorf("Login() = %v", token)
+			}
+		})
+	}
+}
+
+func TestRegisterValidation(t *testing.T) {
+	a := newTestAuth(t, &fakeService{register: func(models.User) (int, error) {
+		return 0, &validation.Error{Violations: []validation.Violation{
+			{Field: validation.FieldEmail, Rule: validation.RuleEmailFormat, Message: "is not a valid email address"},
+			{Field: validation.FieldPassword, Rule: validation.RuleMinLength, Message: "must be at least 8 characters"},
+		}}
+	}})
+	_, err := a.Register(context.Background(), &desc.RegisterRequest{Email: "ann", Password: "pass"})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("Register() code = %v, want InvalidArgument", st.Code())
+	}
+	var fields []string
+	for _, detail := range st.Details() {
+		if bad, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range bad.FieldViolations {
+				fields = append(fields, v.Field+" "+v.Description)
+			}
+		}
+	}
+	want := []string{"email email_format: is not a valid email address", "password min_length: must be at least 8 characters"}
+	if !slices.Equal(fields, want) {
+		t.Errorf("field violations = %q, want %q", fields, want)
+	}
+}
+
+// TestLoginClientInfo checks that forwarded addresses count only from a trusted proxy
+func TestLoginClientInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		peer    string
+		trusted []string
+		ip      string
+	}{
+		{name: "direct", peer: "198.51.100.7:5000", ip: "198.51.100.7"},
+		{name: "untrusted peer", peer: "198.51.100.7:5000", trusted: []string{"10.0.0.0/8"}, ip: "198.51.100.7"},
+		{name: "trusted proxy", peer: "10.0.0.2:5000", trusted: []string{"10.0.0.0/8"}, ip: "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got models.ClientInfo
+			a := newTestAuth(t, &fakeService{login: func(_ models.User, client models.ClientInfo) (models.Token, error) {
+				got = client
+				return models.Token{}, nil
+			}}, tt.trusted...)
+			addr, err := net.ResolveTCPAddr("tcp", tt.peer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(
+				"x-forwarded-for", "203.0.113.9",
+				"user-agent", "app/1.0",
+				"x-device-name", "phone",
+			))
+			if _, err := a.Login(ctx, &desc.LoginRequest{Email: "ann@example.com", Password: "pass"}); err != nil {
+				t.Fatal(err)
+			}
+			want := models.ClientInfo{IP: tt.ip, UserAgent: "app/1.0", DeviceName: "phone"}
+			if got != want {
+				t.Errorf("client = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// TestSessionOwner takes the claims from the auth interceptor, as the session RPCs do
+func TestSessionOwner(t *testing.T) {
+	service := &fakeService{claims: map[string]models.Claims{
+		"user":    {UserId: 1},
+		"support": {UserId: 2, Roles: []string{models.RoleSupport}},
+	}}
+	auth := interceptors.NewAuth(service, "/test")
+	tests := []struct {
+		name      string
+		token     string
+		requested int64
+		owner     int
+		code      codes.Code
+	}{
+		{name: "own sessions", token: "user", owner: 1, code: codes.OK},
+		{name: "own id", token: "user", requested: 1, owner: 1, code: codes.OK},
+		{name: "other user", token: "user", requested: 3, code: codes.PermissionDenied},
+		{name: "support", token: "support", requested: 3, owner: 3, code: codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+tt.token))
+			_, err := auth.Unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test"}, func(ctx context.Context, req any) (any, error) {
+				owner, err := sessionOwner(ctx, tt.requested)
+				if owner != tt.owner {
+					t.Errorf("sessionOwner() = %d, want %d", owner, tt.owner)
+				}
+				return nil, err
+			})
+			if code := status.Code(err); code != tt.code {
+				t.Errorf("sessionOwner() code = %v, want %v", code, tt.code)
+			}
+		})
+	}
+	if _, err := sessionOwner(context.Background(), 0); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("sessionOwner() without claims error = %v, want Unauthenticated", err)
+	}
+}
@@ -0,0 +1,61 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"github.com/d1mitrii/authentication-service/internal/controller/grpc/interceptors"
+	"github.com/d1mitrii/authentication-service/internal/services"
+	"github.com/d1mitrii/authentication-service/internal/services/validation"
+	desc "github.com/d1mitrii/authentication-service/pkg/auth/v1"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (a *Auth) RequestPasswordReset(ctx context.Context, req *desc.RequestPasswordResetRequest) (*desc.RequestPasswordResetResponse, error) {
+	if len(req.Email) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty email provided")
+	}
+	if err := a.service.RequestPasswordReset(ctx, req.Email); err != nil {
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	return &desc.RequestPasswordResetResponse{}, nil
+}
+
+func (a *Auth) ResetPassword(ctx context.Context, req *desc.ResetPasswordRequest) (*desc.ResetPasswordResponse, error) {
+	if len(req.Token) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty token provided")
+	}
+	if err := a.service.ResetPassword(ctx, req.Token, req.Password); err != nil {
+		var invalid *validation.Error
+		switch {
+		case errors.As(err, &invalid):
+			return nil, validationError(invalid)
+		case errors.Is(err, services.ErrInvalidResetToken):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	return &desc.ResetPasswordResponse{}, nil
+}
+
+func (a *Auth) ChangePassword(ctx context.Context, req *desc.ChangePasswordRequest) (*desc.ChangePasswordResponse, error) {
+	claims, ok := interceptors.ClaimsFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "access token required")
+	}
+	err := a.service.ChangePassword(ctx, claims.UserId, req.CurrentPassword, req.NewPassword, req.RefreshToken)
+	if err != nil {
+		var invalid *validation.Error
+		switch {
+		case errors.As(err, &invalid):
+			return nil, validationError(invalid)
+		case errors.Is(err, services.ErrIncorrectPassword):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, services.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	return &desc.ChangePasswordResponse{}, nil
+}
@@ -0,0 +1,70 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"github.com/d1mitrii/authentication-service/internal/controller/grpc/interceptors"
+	"github.com/d1mitrii/authentication-service/internal/converter"
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/services"
+	desc "github.com/d1mitrii/authentication-service/pkg/auth/v1"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (a *Auth) ListSessions(ctx context.Context, req *desc.ListSessionsRequest) (*desc.ListSessionsResponse, error) {
+	userId, err := sessionOwner(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := a.service.ListSessions(ctx, userId)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	return converter.SessionsToResponse(sessions), nil
+}
+
+func (a *Auth) RevokeSession(ctx context.Context, req *desc.RevokeSessionRequest) (*desc.RevokeSessionResponse, error) {
+	if len(req.SessionId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty session id provided")
+	}
+	userId, err := sessionOwner(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.service.RevokeSession(ctx, userId, req.SessionId); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	return &desc.RevokeSessionResponse{}, nil
+}
+
+func (a *Auth) RevokeAllSessions(ctx context.Context, req *desc.RevokeAllSessionsRequest) (*desc.RevokeAllSessionsResponse, error) {
+	userId, err := sessionOwner(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.service.RevokeAllSessions(ctx, userId); err != nil {
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	return &desc.RevokeAllSessionsResponse{}, nil
+}
+
+// sessionOwner returns the user whose sessions are managed, the caller unless
+// another user is requested, which only admin and support staff are allowed to do
+func sessionOwner(ctx context.Context, requested int64) (int, error) {
+	claims, ok := interceptors.ClaimsFromContext(ctx)
+	if !ok {
+		return 0, status.Error(codes.Unauthenticated, "access token required")
+	}
+	if requested == 0 || int(requested) == claims.UserId {
+		return claims.UserId, nil
+	}
+	if !claims.HasRole(models.RoleAdmin, models.RoleSupport) {
+		return 0, status.Error(codes.PermissionDenied, "not allowed to manage sessions of other users")
+	}
+	return int(requested), nil
+}
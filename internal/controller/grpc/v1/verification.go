@@ -0,0 +1,34 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"github.com/d1mitrii/authentication-service/internal/services"
+	desc "github.com/d1mitrii/authentication-service/pkg/auth/v1"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (a *Auth) VerifyEmail(ctx context.Context, req *desc.VerifyEmailRequest) (*desc.VerifyEmailResponse, error) {
+	if len(req.Token) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty token provided")
+	}
+	if err := a.service.VerifyEmail(ctx, req.Token); err != nil {
+		if errors.Is(err, services.ErrInvalidVerificationToken) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	return &desc.VerifyEmailResponse{}, nil
+}
+
+func (a *Auth) ResendVerification(ctx context.Context, req *desc.ResendVerificationRequest) (*desc.ResendVerificationResponse, error) {
+	if len(req.Email) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty email provided")
+	}
+	if err := a.service.ResendVerification(ctx, req.Email); err != nil {
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	return &desc.ResendVerificationResponse{}, nil
+}
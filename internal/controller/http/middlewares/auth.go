@@ -2,50 +2,74 @@ package middlewares
 
 import (
 	"context"
+	"errors"
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/services"
 	"net/http"
 	"strings"
 )
 
-type CtxUserId struct{}
+type CtxClaims struct{}
 type CtxRefreshToken struct{}
 
 const (
 	RefreshCookie string = "refresh-token"
 )
 
-type JWT interface {
-	Parse(token string) (int, error)
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (models.Claims, error)
 }
 
 type AuthMiddleware struct {
-	jwt JWT
+	auth Authenticator
 }
 
-func NewAuthMiddleware(jwt JWT) *AuthMiddleware {
+func NewAuthMiddleware(auth Authenticator) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwt: jwt,
+		auth: auth,
 	}
 }
 
 func (m *AuthMiddleware) JWT(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token, ok := getBearerToken(r.Header.Get("Authorization"))
+		token, ok := BearerToken(r)
 		if !ok {
 			http.Error(w, "incorrect authorization header", http.StatusUnauthorized)
 			return
 		}
-		id, err := m.jwt.Parse(token)
+		claims, err := m.auth.Authenticate(r.Context(), token)
 		if err != nil {
-			http.Error(w, "incorrect access token", http.StatusForbidden)
+			switch {
+			case errors.Is(err, services.ErrInvalidToken):
+				http.Error(w, "incorrect access token", http.StatusForbidden)
+			default:
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+			}
 			return
 		}
-		ctx := context.WithValue(r.Context(), CtxUserId{}, id)
+		ctx := context.WithValue(r.Context(), CtxClaims{}, claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func getBearerToken(header string) (string, bool) {
-	splitHeader := strings.Split(header, "Bearer ")
+// RequireRole lets through requests whose access token grants any of the roles,
+// it must run after JWT
+func (m *AuthMiddleware) RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(CtxClaims{}).(models.Claims)
+			if !ok || !claims.HasRole(roles...) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// BearerToken returns the token from the Authorization header of the request
+func BearerToken(r *http.Request) (string, bool) {
+	splitHeader := strings.Split(r.Header.Get("Authorization"), "Bearer ")
 	if len(splitHeader) != 2 {
 		return "", false
 	}
@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/pkg/realip"
+	"net"
+	"net/http"
+)
+
+const (
+	DeviceNameHeader string = "X-Device-Name"
+)
+
+// RealIP rewrites RemoteAddr to the client address, proxy headers count only when the peer is a trusted proxy
+func RealIP(proxies *realip.Proxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.RemoteAddr = proxies.ClientIP(r.RemoteAddr, r.Header.Get("X-Real-IP"), r.Header.Values("X-Forwarded-For"))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientInfo describes the caller of the request.
+// RemoteAddr is already rewritten by RealIP when the service runs behind a proxy.
+func ClientInfo(r *http.Request) models.ClientInfo {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return models.ClientInfo{
+		IP:         ip,
+		UserAgent:  r.UserAgent(),
+		DeviceName: r.Header.Get(DeviceNameHeader),
+	}
+}
@@ -0,0 +1,71 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/d1mitrii/authentication-service/internal/controller/http/middlewares"
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/services"
+)
+
+const (
+	// Verifiers refetch the key set when they see an unknown kid,
+	// so a short max-age is enough to pick up rotated keys
+	jwksCacheControl      = "public, max-age=300, must-revalidate"
+	discoveryCacheControl = "public, max-age=3600"
+)
+
+// openidConfiguration only lists what the service backs: there is no authorization endpoint
+// and no ID tokens are issued, so the response types and ID token algorithms are left out
+type openidConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+}
+
+func (h *Handler) jwks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", jwksCacheControl)
+	json.NewEncoder(w).Encode(h.service.JWKS())
+}
+
+func (h *Handler) openidConfiguration(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", discoveryCacheControl)
+	json.NewEncoder(w).Encode(openidConfiguration{
+		Issuer:                            h.issuer,
+		JWKSURI:                           h.issuer + "/.well-known/jwks.json",
+		TokenEndpoint:                     h.issuer + "/token",
+		TokenEndpointAuthMethodsSupported: []string{"none", "client_secret_basic", "client_secret_post"},
+		UserinfoEndpoint:                  h.issuer + "/userinfo",
+		SubjectTypesSupported:             []string{"public"},
+		GrantTypesSupported:               []string{grantRefreshToken},
+		ClaimsSupported:                   []string{"sub", "email", "email_verified"},
+		IntrospectionEndpoint:             h.issuer + "/introspect",
+		RevocationEndpoint:                h.issuer + "/revoke",
+	})
+}
+
+func (h *Handler) userInfo(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(middlewares.CtxClaims{}).(models.Claims)
+	info, err := h.service.UserInfo(r.Context(), claims.UserId)
+	if err != nil {
+		if err == services.ErrUserNotFound {
+			http.Error(w, "user not found", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(info)
+}
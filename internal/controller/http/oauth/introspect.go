@@ -0,0 +1,83 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+)
+
+type ClientAuthenticator interface {
+	Authenticate(id, secret string) bool
+}
+
+type introspectionResponse struct {
+	Active    bool     `json:"active"`
+	TokenType string   `json:"token_type,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+}
+
+// clientAuth requires client credentials sent with HTTP Basic authentication
+// or as client_id and client_secret form parameters (RFC 6749 section 2.3.1)
+func (h *Handler) clientAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok {
+			id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+		}
+		if len(id) == 0 || !h.clients.Authenticate(id, secret) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+			writeError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handler) introspect(w http.ResponseWriter, r *http.Request) {
+	token := r.PostFormValue("token")
+	if len(token) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid_request", "token parameter is required")
+		return
+	}
+	result, err := h.service.Introspect(r.Context(), token, r.PostFormValue("token_type_hint"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(toIntrospectionResponse(result))
+}
+
+func toIntrospectionResponse(result models.Introspection) introspectionResponse {
+	if !result.Active {
+		return introspectionResponse{}
+	}
+	response := introspectionResponse{
+		Active:    true,
+		TokenType: result.TokenType,
+		Subject:   strconv.Itoa(result.UserId),
+		Username:  result.Email,
+		Scope:     result.Scope,
+		Issuer:    result.Issuer,
+		Audience:  result.Audience,
+		ID:        result.ID,
+	}
+	if !result.ExpiresAt.IsZero() {
+		response.ExpiresAt = result.ExpiresAt.Unix()
+	}
+	if !result.IssuedAt.IsZero() {
+		response.IssuedAt = result.IssuedAt.Unix()
+	}
+	return response
+}
@@ -0,0 +1,294 @@
+package oauth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/services"
+	"github.com/d1mitrii/authentication-service/internal/services/clients"
+	"github.com/d1mitrii/authentication-service/internal/services/servicestest"
+)
+
+const testEmail = "ann@example.com"
+
+// newTestRouter serves the endpoints for a registered user, the client "api" has the secret "secret"
+func newTestRouter(t *testing.T, opts ...services.Option) (http.Handler, *services.Services, int) {
+	t.Helper()
+	s, _ := servicestest.New(t, opts...)
+	id, err := s.Register(context.Background(), models.User{Email: testEmail, Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := clients.New(map[string]string{"api": "secret"})
+	return New(s, registry, servicestest.Issuer+"/").Routes(), s, id
+}
+
+func login(t *testing.T, s *services.Services) models.Token {
+	t.Helper()
+	token, err := s.Login(context.Background(), models.User{Email: testEmail, Password: "correct horse"}, models.ClientInfo{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func postForm(router http.Handler, path string, form url.Values, client ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if len(client) == 2 {
+		r.SetBasicAuth(client[0], client[1])
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func get(router http.Handler, path string, bearer string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	if bearer != "" {
+		r.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.NewDecoder(w.Body).Decode(&v); err != nil {
+		t.Fatalf("decode %T: %v", v, err)
+	}
+	return v
+}
+
+func TestDiscovery(t *testing.T) {
+	router, _, _ := newTestRouter(t)
+	w := get(router, "/.well-known/openid-configuration", "")
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != discoveryCacheControl {
+		t.Fatalf("GET openid-configuration = %d, Cache-Control %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+	config := decode[openidConfiguration](t, w)
+	if config.Issuer != servicestest.Issuer {
+		t.Errorf("issuer = %q, want %q without the trailing slash", config.Issuer, servicestest.Issuer)
+	}
+	endpoints := map[string]string{
+		config.JWKSURI:               "/.well-known/jwks.json",
+		config.TokenEndpoint:         "/token",
+		config.UserinfoEndpoint:      "/userinfo",
+		config.IntrospectionEndpoint: "/introspect",
+		config.RevocationEndpoint:    "/revoke",
+	}
+	for endpoint, path := range endpoints {
+		if endpoint != servicestest.Issuer+path {
+			t.Errorf("endpoint = %q, want %q", endpoint, servicestest.Issuer+path)
+		}
+	}
+	if len(config.GrantTypesSupported) != 1 || config.GrantTypesSupported[0] != grantRefreshToken {
+		t.Errorf("grant_types_supported = %v, only the refresh token grant is served", config.GrantTypesSupported)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	router, s, _ := newTestRouter(t)
+	w := get(router, "/.well-known/jwks.json", "")
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != jwksCacheControl {
+		t.Fatalf("GET jwks.json = %d, Cache-Control %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+	set := decode[models.JWKSet](t, w)
+	if len(set.Keys) != 1 {
+		t.Fatalf("keys = %+v, want the signing key", set.Keys)
+	}
+	key := set.Keys[0]
+	if key.Kty != "OKP" || key.Crv != "Ed25519" || key.Alg != "EdDSA" || key.Use != "sig" || key.X == "" {
+		t.Errorf("key = %+v", key)
+	}
+	segment, _, _ := strings.Cut(login(t, s).Access, ".")
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header struct {
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil || header.Kid != key.Kid {
+		t.Errorf("tokens are signed with key %q, the set publishes %q", header.Kid, key.Kid)
+	}
+}
+
+func TestTokenRefreshGrant(t *testing.T) {
+	router, s, _ := newTestRouter(t)
+	token := login(t, s)
+
+	w := postForm(router, "/token", url.Values{"grant_type": {grantRefreshToken}, "refresh_token": {token.Refresh}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /token = %d %s", w.Code, w.Body)
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", w.Header().Get("Cache-Control"))
+	}
+	response := decode[tokenResponse](t, w)
+	if response.TokenType != "Bearer" || response.ExpiresIn != int64(servicestest.AccessTTL.Seconds()) ||
+		response.AccessToken == "" || response.RefreshToken == "" || response.RefreshToken == token.Refresh {
+		t.Errorf("response = %+v", response)
+	}
+
+	// confidential clients authenticate, with Basic or in the form
+	w = postForm(router, "/token", url.Values{"grant_type": {grantRefreshToken}, "refresh_token": {response.RefreshToken}}, "api", "secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /token with client credentials = %d %s", w.Code, w.Body)
+	}
+	response = decode[tokenResponse](t, w)
+	form := url.Values{"grant_type": {grantRefreshToken}, "refresh_token": {response.RefreshToken}, "client_id": {"api"}, "client_secret": {"secret"}}
+	if w = postForm(router, "/token", form); w.Code != http.StatusOK {
+		t.Fatalf("POST /token with credentials in the form = %d %s", w.Code, w.Body)
+	}
+}
+
+func TestTokenErrors(t *testing.T) {
+	router, s, _ := newTestRouter(t)
+	token := login(t, s)
+
+	tests := []struct {
+		name   string
+		form   url.Values
+		client []string
+		status int
+		code   string
+	}{
+		{name: "wrong client secret", form: url.Values{"grant_type": {grantRefreshToken}, "refresh_token": {token.Refresh}}, client: []string{"api", "wrong"}, status: http.StatusUnauthorized, code: "invalid_client"},
+		{name: "wrong secret in the form", form: url.Values{"grant_type": {grantRefreshToken}, "refresh_token": {token.Refresh}, "client_id": {"api"}, "client_secret": {"wrong"}}, status: http.StatusUnauthorized, code: "invalid_client"},
+		{name: "no grant type", form: url.Values{"refresh_token": {token.Refresh}}, status: http.StatusBadRequest, code: "invalid_request"},
+		{name: "password grant", form: url.Values{"grant_type": {"password"}, "username": {testEmail}, "password": {"correct horse"}}, status: http.StatusBadRequest, code: "unsupported_grant_type"},
+		{name: "no refresh token", form: url.Values{"grant_type": {grantRefreshToken}}, status: http.StatusBadRequest, code: "invalid_request"},
+		{name: "unknown refresh token", form: url.Values{"grant_type": {grantRefreshToken}, "refresh_token": {"rt_unknown"}}, status: http.StatusBadRequest, code: "invalid_grant"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postForm(router, "/token", tt.form, tt.client...)
+			if w.Code != tt.status {
+				t.Fatalf("POST /token = %d %s, want %d", w.Code, w.Body, tt.status)
+			}
+			if w.Header().Get("Cache-Control") != "no-store" {
+				t.Errorf("Cache-Control = %q, want no-store", w.Header().Get("Cache-Control"))
+			}
+			if response := decode[errorResponse](t, w); response.Error != tt.code {
+				t.Errorf("error = %q, want %q", response.Error, tt.code)
+			}
+		})
+	}
+
+	// none of the failed calls used the token up
+	if w := postForm(router, "/token", url.Values{"grant_type": {grantRefreshToken}, "refresh_token": {token.Refresh}}); w.Code != http.StatusOK {
+		t.Errorf("POST /token after the failures = %d %s", w.Code, w.Body)
+	}
+}
+
+func TestTokenExpiredSession(t *testing.T) {
+	// the services read the clock in the background as well
+	var idle atomic.Int64
+	clock := func() time.Time { return time.Now().Add(time.Duration(idle.Load())) }
+	router, s, _ := newTestRouter(t, services.SessionTimeouts(time.Hour, 0), services.Clock(clock))
+	token := login(t, s)
+
+	idle.Store(int64(2 * time.Hour))
+	w := postForm(router, "/token", url.Values{"grant_type": {grantRefreshToken}, "refresh_token": {token.Refresh}})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("POST /token of an idle session = %d %s, want 400", w.Code, w.Body)
+	}
+	if response := decode[errorResponse](t, w); response.Error != "invalid_grant" {
+		t.Errorf("error = %q, want invalid_grant", response.Error)
+	}
+}
+
+func TestIntrospect(t *testing.T) {
+	router, s, id := newTestRouter(t)
+	token := login(t, s)
+
+	w := postForm(router, "/introspect", url.Values{"token": {token.Access}})
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("POST /introspect without client credentials = %d, want 401 with a challenge", w.Code)
+	}
+	w = postForm(router, "/introspect", url.Values{"token": {token.Access}}, "api", "wrong")
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("POST /introspect with a wrong secret = %d, want 401", w.Code)
+	}
+
+	w = postForm(router, "/introspect", url.Values{"token": {token.Access}}, "api", "secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /introspect = %d %s", w.Code, w.Body)
+	}
+	response := decode[introspectionResponse](t, w)
+	if !response.Active || response.Subject != strconv.Itoa(id) || response.Username != testEmail ||
+		response.Issuer != servicestest.Issuer || response.ExpiresAt == 0 {
+		t.Errorf("introspection of the access token = %+v", response)
+	}
+
+	w = postForm(router, "/introspect", url.Values{"token": {token.Refresh}, "token_type_hint": {"refresh_token"}}, "api", "secret")
+	if response := decode[introspectionResponse](t, w); !response.Active || response.Subject != strconv.Itoa(id) {
+		t.Errorf("introspection of the refresh token = %+v", response)
+	}
+
+	// an inactive token gets nothing but "active": false (RFC 7662 section 2.2)
+	w = postForm(router, "/introspect", url.Values{"token": {"garbage"}}, "api", "secret")
+	if body := strings.TrimSpace(w.Body.String()); w.Code != http.StatusOK || body != `{"active":false}` {
+		t.Errorf("introspection of garbage = %d %s", w.Code, body)
+	}
+	w = postForm(router, "/introspect", url.Values{}, "api", "secret")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("POST /introspect without a token = %d, want 400", w.Code)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	router, s, _ := newTestRouter(t)
+	token := login(t, s)
+
+	for _, revoked := range []string{"garbage", token.Refresh, token.Refresh} {
+		if w := postForm(router, "/revoke", url.Values{"token": {revoked}}); w.Code != http.StatusOK {
+			t.Errorf("POST /revoke = %d %s, want 200", w.Code, w.Body)
+		}
+	}
+	w := postForm(router, "/token", url.Values{"grant_type": {grantRefreshToken}, "refresh_token": {token.Refresh}})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("POST /token with a revoked refresh token = %d, want 400", w.Code)
+	}
+
+	token = login(t, s)
+	if w := postForm(router, "/revoke", url.Values{"token": {token.Access}, "token_type_hint": {"access_token"}}); w.Code != http.StatusOK {
+		t.Fatalf("POST /revoke of an access token = %d %s", w.Code, w.Body)
+	}
+	if w := get(router, "/userinfo", token.Access); w.Code != http.StatusForbidden {
+		t.Errorf("GET /userinfo with a revoked access token = %d, want 403", w.Code)
+	}
+	if w := postForm(router, "/revoke", url.Values{}); w.Code != http.StatusBadRequest {
+		t.Errorf("POST /revoke without a token = %d, want 400", w.Code)
+	}
+}
+
+func TestUserInfo(t *testing.T) {
+	router, s, id := newTestRouter(t)
+	token := login(t, s)
+
+	w := get(router, "/userinfo", token.Access)
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("GET /userinfo = %d %s", w.Code, w.Body)
+	}
+	info := decode[models.UserInfo](t, w)
+	if info.Subject != strconv.Itoa(id) || info.Email != testEmail || info.EmailVerified {
+		t.Errorf("userinfo = %+v", info)
+	}
+	if w := get(router, "/userinfo", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /userinfo without a token = %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,23 @@
+package oauth
+
+import (
+	"net/http"
+)
+
+// revoke always answers 200 for unknown and invalid tokens (RFC 7009 section 2.2).
+// Possession of the token is enough to revoke it, so public clients
+// such as mobile apps don't need client credentials.
+func (h *Handler) revoke(w http.ResponseWriter, r *http.Request) {
+	token := r.PostFormValue("token")
+	if len(token) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid_request", "token parameter is required")
+		return
+	}
+	if err := h.service.Revoke(r.Context(), token); err != nil {
+		w.Header().Set("Retry-After", "5")
+		writeError(w, http.StatusServiceUnavailable, "server_error", "")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
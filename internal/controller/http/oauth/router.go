@@ -0,0 +1,48 @@
+package oauth
+
+import (
+	"strings"
+
+	"github.com/d1mitrii/authentication-service/internal/controller/http/middlewares"
+	"github.com/d1mitrii/authentication-service/internal/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service *services.Services
+	clients ClientAuthenticator
+	issuer  string
+}
+
+func New(s *services.Services, clients ClientAuthenticator, issuer string) *Handler {
+	return &Handler{
+		service: s,
+		clients: clients,
+		issuer:  strings.TrimSuffix(issuer, "/"),
+	}
+}
+
+func (h *Handler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/.well-known/jwks.json", h.jwks)
+	r.Get("/.well-known/openid-configuration", h.openidConfiguration)
+	r.Post("/token", h.token)
+	r.Post("/revoke", h.revoke)
+
+	r.Group(func(r chi.Router) {
+		r.Use(h.clientAuth)
+		r.Post("/introspect", h.introspect)
+	})
+
+	auth := middlewares.NewAuthMiddleware(h.service)
+
+	r.Group(func(r chi.Router) {
+		r.Use(auth.JWT)
+		r.Get("/userinfo", h.userInfo)
+		r.Post("/userinfo", h.userInfo)
+	})
+
+	return r
+}
@@ -0,0 +1,80 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/d1mitrii/authentication-service/internal/controller/http/middlewares"
+	"github.com/d1mitrii/authentication-service/internal/services"
+)
+
+const grantRefreshToken = "refresh_token"
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type errorResponse struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// token is the token endpoint (RFC 6749 section 3.2) for the refresh token grant,
+// tokens are first issued by the login endpoints. Public clients may leave out client credentials,
+// credentials that are sent have to be valid.
+func (h *Handler) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if (ok || len(secret) != 0) && !h.clients.Authenticate(id, secret) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		writeError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	}
+
+	switch r.PostFormValue("grant_type") {
+	case grantRefreshToken:
+	case "":
+		writeError(w, http.StatusBadRequest, "invalid_request", "grant_type parameter is required")
+		return
+	default:
+		writeError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+		return
+	}
+	refreshToken := r.PostFormValue("refresh_token")
+	if len(refreshToken) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid_request", "refresh_token parameter is required")
+		return
+	}
+	token, err := h.service.RefreshSession(r.Context(), refreshToken, middlewares.ClientInfo(r))
+	if err != nil {
+		switch err {
+		case services.ErrUserNotFound, services.ErrSessionNotFound, services.ErrSessionExpired:
+			writeError(w, http.StatusBadRequest, "invalid_grant", err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "server_error", "")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	json.NewEncoder(w).Encode(tokenResponse{
+		AccessToken:  token.Access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(h.service.JWT.AccessTTL().Seconds()),
+		RefreshToken: token.Refresh,
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, code string, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: code, Description: description})
+}
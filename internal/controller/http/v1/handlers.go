@@ -2,28 +2,41 @@ package v1
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/d1mitrii/authentication-service/internal/controller/http/middlewares"
 	"github.com/d1mitrii/authentication-service/internal/models"
-	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
 	"github.com/d1mitrii/authentication-service/internal/services"
+	"github.com/d1mitrii/authentication-service/internal/services/validation"
 	"net/http"
+	"time"
 )
 
 func (h *Handler) signUp(w http.ResponseWriter, r *http.Request) {
-	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
-	if err != nil {
+	var request struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
 		http.Error(w, "incorrect request body", http.StatusBadRequest)
 		return
 	}
-	id, err := h.service.Register(r.Context(), user)
+	id, err := h.service.Register(r.Context(), models.User{
+		Email:    request.Email,
+		Password: request.Password,
+	})
 	if err != nil {
-		if err == repoerrors.ErrAlreadyExist {
+		var invalid *validation.Error
+		switch {
+		case errors.As(err, &invalid):
+			validationError(w, invalid)
+		case err == services.ErrUserAlreadyExist:
 			http.Error(w, "user already exist", http.StatusBadRequest)
-			return
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
 		}
-		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
 
@@ -32,19 +45,33 @@ func (h *Handler) signUp(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response{id})
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response{id})
+}
+
+// validationError responds with 400 and every violated rule
+func validationError(w http.ResponseWriter, err *validation.Error) {
+	type response struct {
+		Error      string                 `json:"error"`
+		Violations []validation.Violation `json:"violations"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(response{"validation failed", err.Violations})
 }
 
 func (h *Handler) logIn(w http.ResponseWriter, r *http.Request) {
-	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
+	var request struct {
+		models.User
+		RememberMe bool `json:"remember_me"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		http.Error(w, "incorrect request body", http.StatusBadRequest)
 		return
 	}
 
-	jwt, err := h.service.Login(r.Context(), user)
+	jwt, err := h.service.Login(r.Context(), request.User, middlewares.ClientInfo(r), request.RememberMe)
 
 	if err != nil {
 		switch err {
@@ -52,19 +79,15 @@ func (h *Handler) logIn(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "internal server error", http.StatusInternalServerError)
 		case services.ErrIncorrectPassword:
 			http.Error(w, err.Error(), http.StatusBadRequest)
+		case services.ErrSessionLimit, services.ErrEmailNotVerified:
+			http.Error(w, err.Error(), http.StatusForbidden)
 		default:
 			http.Error(w, "internal server error", http.StatusInternalServerError)
 		}
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     middlewares.RefreshCookie,
-		Value:    jwt.Refresh,
-		Path:     "/",
-		MaxAge:   int(h.service.JWT.RefreshTTL().Seconds()),
-		HttpOnly: true,
-	})
+	setRefreshCookie(w, jwt)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(jwt)
@@ -73,7 +96,8 @@ func (h *Handler) logIn(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) logOut(w http.ResponseWriter, r *http.Request) {
 	refreshToken := r.Context().Value(middlewares.CtxRefreshToken{}).(string)
-	if h.service.Logout(r.Context(), refreshToken) != nil {
+	accessToken, _ := middlewares.BearerToken(r)
+	if h.service.Logout(r.Context(), refreshToken, accessToken) != nil {
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -89,26 +113,42 @@ func (h *Handler) logOut(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) refresh(w http.ResponseWriter, r *http.Request) {
 	refreshToken := r.Context().Value(middlewares.CtxRefreshToken{}).(string)
-	jwt, err := h.service.RefreshSession(r.Context(), refreshToken)
+	jwt, err := h.service.RefreshSession(r.Context(), refreshToken, middlewares.ClientInfo(r))
 	if err != nil {
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		switch err {
+		case services.ErrSessionNotFound, services.ErrSessionExpired:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     middlewares.RefreshCookie,
-		Value:    jwt.Refresh,
-		Path:     "/",
-		HttpOnly: true,
-	})
+	setRefreshCookie(w, jwt)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(jwt)
 	w.WriteHeader(http.StatusOK)
 }
 
+// setRefreshCookie stores the refresh token in the browser, it outlives the browser session
+// only when the user asked to be remembered
+func setRefreshCookie(w http.ResponseWriter, token models.Token) {
+	cookie := &http.Cookie{
+		Name:     middlewares.RefreshCookie,
+		Value:    token.Refresh,
+		Path:     "/",
+		HttpOnly: true,
+	}
+	if token.Persistent {
+		cookie.MaxAge = int(time.Until(token.RefreshExpiresAt).Seconds())
+	}
+	http.SetCookie(w, cookie)
+}
+
 func (h *Handler) secret(w http.ResponseWriter, r *http.Request) {
-	helloStr := fmt.Sprintf("Hi, user: %d", r.Context().Value(middlewares.CtxUserId{}).(int))
+	claims := r.Context().Value(middlewares.CtxClaims{}).(models.Claims)
+	helloStr := fmt.Sprintf("Hi, user: %d", claims.UserId)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(helloStr)
 	w.WriteHeader(http.StatusOK)
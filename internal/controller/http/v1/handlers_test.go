@@ -0,0 +1,361 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/d1mitrii/authentication-service/internal/controller/http/middlewares"
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/services"
+	"github.com/d1mitrii/authentication-service/internal/services/servicestest"
+)
+
+const (
+	testEmail    = "ann@example.com"
+	testPassword = "correct horse"
+)
+
+func newTestRouter(t *testing.T, opts ...services.Option) (http.Handler, *services.Services, *servicestest.Outbox) {
+	t.Helper()
+	s, box := servicestest.New(t, opts...)
+	return New(s).Routes(), s, box
+}
+
+// request builds a request with body encoded as JSON, a string body is sent as is
+func request(method, path string, body any) *http.Request {
+	var data []byte
+	switch body := body.(type) {
+	case nil:
+	case string:
+		data = []byte(body)
+	default:
+		data, _ = json.Marshal(body)
+	}
+	r := httptest.NewRequest(method, path, bytes.NewReader(data))
+	if len(data) != 0 {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	return r
+}
+
+func serve(router http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func withBearer(r *http.Request, token string) *http.Request {
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func withRefresh(r *http.Request, token string) *http.Request {
+	r.AddCookie(&http.Cookie{Name: middlewares.RefreshCookie, Value: token})
+	return r
+}
+
+func refreshCookie(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == middlewares.RefreshCookie {
+			return cookie
+		}
+	}
+	t.Fatalf("no %s cookie in %v", middlewares.RefreshCookie, w.Header())
+	return nil
+}
+
+func signUp(t *testing.T, router http.Handler, email string) {
+	t.Helper()
+	w := serve(router, request(http.MethodPost, "/signup", map[string]string{"email": email, "password": testPassword}))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /signup = %d %s", w.Code, w.Body)
+	}
+}
+
+func logIn(t *testing.T, router http.Handler, email string, rememberMe bool) models.Token {
+	t.Helper()
+	body := map[string]any{"email": email, "password": testPassword, "remember_me": rememberMe}
+	w := serve(router, request(http.MethodPost, "/login", body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /login = %d %s", w.Code, w.Body)
+	}
+	var token models.Token
+	if err := json.NewDecoder(w.Body).Decode(&token); err != nil {
+		t.Fatal(err)
+	}
+	if cookie := refreshCookie(t, w); cookie.Value != token.Refresh || !cookie.HttpOnly {
+		t.Errorf("cookie = %+v, want the HttpOnly refresh token", cookie)
+	}
+	return token
+}
+
+func TestSignUp(t *testing.T) {
+	router, _, _ := newTestRouter(t)
+
+	w := serve(router, request(http.MethodPost, "/signup", map[string]string{"email": testEmail, "password": testPassword}))
+	if w.Code != http.StatusCreated || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("POST /signup = %d %s", w.Code, w.Body)
+	}
+	var created struct {
+		Id int `json:"id"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil || created.Id == 0 {
+		t.Errorf("response = %+v, %v, want the user id", created, err)
+	}
+
+	tests := []struct {
+		name string
+		body any
+		want string
+	}{
+		{name: "existing user", body: map[string]string{"email": "ann@EXAMPLE.com", "password": testPassword}, want: "user already exist"},
+		{name: "short password", body: map[string]string{"email": "bob@example.com", "password": "short"}, want: `"field":"password"`},
+		{name: "invalid email", body: map[string]string{"email": "bob", "password": testPassword}, want: `"field":"email"`},
+		{name: "unknown field", body: map[string]string{"email": "bob@example.com", "password": testPassword, "role": "admin"}, want: "incorrect request body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, request(http.MethodPost, "/signup", tt.body))
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("POST /signup = %d %s, want 400 with %q", w.Code, w.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogIn(t *testing.T) {
+	router, _, _ := newTestRouter(t)
+	signUp(t, router, testEmail)
+
+	token := logIn(t, router, testEmail, false)
+	if token.Access == "" || token.Refresh == "" {
+		t.Errorf("token = %+v", token)
+	}
+
+	w := serve(router, request(http.MethodPost, "/login", map[string]any{"email": testEmail, "password": testPassword, "remember_me": true}))
+	if cookie := refreshCookie(t, w); cookie.MaxAge <= 0 {
+		t.Errorf("remember me cookie MaxAge = %d, want it to outlive the browser session", cookie.MaxAge)
+	}
+	w = serve(router, request(http.MethodPost, "/login", map[string]any{"email": testEmail, "password": "wrong horse"}))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("POST /login with a wrong password = %d, want 400", w.Code)
+	}
+	w = serve(router, request(http.MethodPost, "/login", "{"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("POST /login with a broken body = %d, want 400", w.Code)
+	}
+}
+
+func TestRefreshAndLogOut(t *testing.T) {
+	router, _, _ := newTestRouter(t)
+	signUp(t, router, testEmail)
+	token := logIn(t, router, testEmail, false)
+
+	w := serve(router, request(http.MethodGet, "/refresh", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("GET /refresh without the cookie = %d, want 400", w.Code)
+	}
+	w = serve(router, withRefresh(request(http.MethodGet, "/refresh", nil), token.Refresh))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /refresh = %d %s", w.Code, w.Body)
+	}
+	rotated := refreshCookie(t, w).Value
+	if rotated == token.Refresh {
+		t.Error("refresh didn't rotate the token")
+	}
+	w = serve(router, withRefresh(request(http.MethodGet, "/refresh", nil), token.Refresh))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /refresh with the rotated token = %d, want 401", w.Code)
+	}
+
+	// reusing the rotated token revoked the session, start a new one
+	token = logIn(t, router, testEmail, false)
+	w = serve(router, withBearer(withRefresh(request(http.MethodGet, "/logout", nil), token.Refresh), token.Access))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /logout = %d %s", w.Code, w.Body)
+	}
+	if cookie := refreshCookie(t, w); cookie.Value != "" || cookie.MaxAge >= 0 {
+		t.Errorf("cookie after logout = %+v, want it deleted", cookie)
+	}
+	w = serve(router, withRefresh(request(http.MethodGet, "/refresh", nil), token.Refresh))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /refresh after logout = %d, want 401", w.Code)
+	}
+	w = serve(router, withBearer(request(http.MethodGet, "/secret", nil), token.Access))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("GET /secret with the access token of the logout = %d, want 403", w.Code)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	router, s, box := newTestRouter(t)
+	signUp(t, router, testEmail)
+	token := servicestest.LinkToken(t, s, box)
+
+	w := serve(router, request(http.MethodGet, "/verify-email?token="+token, nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<form method="post">`) {
+		t.Fatalf("GET /verify-email = %d %s, want the confirmation form", w.Code, w.Body)
+	}
+	if w.Header().Get("Referrer-Policy") != "no-referrer" {
+		t.Errorf("Referrer-Policy = %q, the page URL holds the token", w.Header().Get("Referrer-Policy"))
+	}
+
+	form := func(token string) *http.Request {
+		r := request(http.MethodPost, "/verify-email?token="+token, nil)
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+	w = serve(router, form("wrong"))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid or expired") {
+		t.Errorf("POST /verify-email with a wrong token = %d %s", w.Code, w.Body)
+	}
+	// opening the link didn't use the token up
+	w = serve(router, form(token))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "is verified") {
+		t.Errorf("POST /verify-email = %d %s", w.Code, w.Body)
+	}
+	w = serve(router, request(http.MethodPost, "/verify-email", map[string]string{"token": token}))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("POST /verify-email with a used token = %d, want 400", w.Code)
+	}
+	w = serve(router, request(http.MethodGet, "/verify-email", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("GET /verify-email without a token = %d, want 400", w.Code)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	router, s, box := newTestRouter(t)
+	signUp(t, router, testEmail)
+	token := logIn(t, router, testEmail, false)
+	// the verification email goes out first
+	s.Wait()
+
+	for _, email := range []string{testEmail, "bob@example.com"} {
+		w := serve(router, request(http.MethodPost, "/password/forgot", map[string]string{"email": email}))
+		if w.Code != http.StatusAccepted {
+			t.Errorf("POST /password/forgot for %q = %d, want 202", email, w.Code)
+		}
+	}
+	link := servicestest.LinkToken(t, s, box)
+
+	reset := func(token, password string) *httptest.ResponseRecorder {
+		return serve(router, request(http.MethodPost, "/password/reset", map[string]string{"token": token, "password": password}))
+	}
+	if w := reset("wrong", "battery staple"); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid or expired") {
+		t.Errorf("POST /password/reset with a wrong token = %d %s", w.Code, w.Body)
+	}
+	if w := reset(link, "short"); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"field":"password"`) {
+		t.Errorf("POST /password/reset with a weak password = %d %s", w.Code, w.Body)
+	}
+	if w := reset(link, "battery staple"); w.Code != http.StatusNoContent {
+		t.Fatalf("POST /password/reset = %d %s", w.Code, w.Body)
+	}
+
+	w := serve(router, withRefresh(request(http.MethodGet, "/refresh", nil), token.Refresh))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /refresh after the reset = %d, want 401", w.Code)
+	}
+	w = serve(router, request(http.MethodPost, "/login", map[string]string{"email": testEmail, "password": "battery staple"}))
+	if w.Code != http.StatusOK {
+		t.Errorf("POST /login with the new password = %d, want 200", w.Code)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	router, _, _ := newTestRouter(t)
+	signUp(t, router, testEmail)
+	other := logIn(t, router, testEmail, false)
+	token := logIn(t, router, testEmail, false)
+
+	change := func(current string) *httptest.ResponseRecorder {
+		body := map[string]any{"current_password": current, "new_password": "battery staple", "keep_session": true}
+		return serve(router, withBearer(withRefresh(request(http.MethodPost, "/password/change", body), token.Refresh), token.Access))
+	}
+	if w := change("wrong horse"); w.Code != http.StatusBadRequest {
+		t.Errorf("POST /password/change with a wrong password = %d, want 400", w.Code)
+	}
+	if w := change(testPassword); w.Code != http.StatusNoContent {
+		t.Fatalf("POST /password/change = %d %s", w.Code, w.Body)
+	}
+
+	w := serve(router, withRefresh(request(http.MethodGet, "/refresh", nil), token.Refresh))
+	if w.Code != http.StatusOK {
+		t.Errorf("GET /refresh of the kept session = %d, want 200", w.Code)
+	}
+	w = serve(router, withRefresh(request(http.MethodGet, "/refresh", nil), other.Refresh))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /refresh of another session = %d, want 401", w.Code)
+	}
+	w = serve(router, request(http.MethodPost, "/login", map[string]string{"email": testEmail, "password": "battery staple"}))
+	if w.Code != http.StatusOK {
+		t.Errorf("POST /login with the new password = %d, want 200", w.Code)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	router, s, _ := newTestRouter(t)
+	signUp(t, router, testEmail)
+	signUp(t, router, "bob@example.com")
+	logIn(t, router, testEmail, false)
+	token := logIn(t, router, testEmail, false)
+
+	list := func() []models.RefreshSession {
+		t.Helper()
+		w := serve(router, withBearer(request(http.MethodGet, "/sessions", nil), token.Access))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /sessions = %d %s", w.Code, w.Body)
+		}
+		var response struct {
+			Sessions []models.RefreshSession `json:"sessions"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Sessions
+	}
+	sessions := list()
+	if len(sessions) != 2 {
+		t.Fatalf("GET /sessions = %+v, want 2 sessions", sessions)
+	}
+	if sessions[0].IP != "192.0.2.1" {
+		t.Errorf("session IP = %q, want the address of the request", sessions[0].IP)
+	}
+
+	// revoking the caller's own session would end its access token too
+	claims, err := s.Authenticate(context.Background(), token.Access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := sessions[0].Id
+	if other == claims.SessionId {
+		other = sessions[1].Id
+	}
+
+	w := serve(router, withBearer(request(http.MethodDelete, "/sessions/unknown", nil), token.Access))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("DELETE /sessions/unknown = %d, want 404", w.Code)
+	}
+	w = serve(router, withBearer(request(http.MethodDelete, "/sessions/"+other, nil), token.Access))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("DELETE /sessions/{id} = %d %s", w.Code, w.Body)
+	}
+	if sessions := list(); len(sessions) != 1 {
+		t.Errorf("GET /sessions after a revoke = %+v, want 1 session", sessions)
+	}
+
+	w = serve(router, withBearer(request(http.MethodGet, "/users/2/sessions/", nil), token.Access))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("GET /users/2/sessions/ without a support role = %d, want 403", w.Code)
+	}
+	w = serve(router, request(http.MethodGet, "/sessions", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /sessions without a token = %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,91 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/d1mitrii/authentication-service/internal/controller/http/middlewares"
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/services"
+	"github.com/d1mitrii/authentication-service/internal/services/validation"
+	"net/http"
+)
+
+// forgotPassword answers 202 whether or not the email belongs to an account
+func (h *Handler) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Email == "" {
+		http.Error(w, "incorrect request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.RequestPasswordReset(r.Context(), request.Email); err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) resetPassword(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil || request.Token == "" {
+		http.Error(w, "incorrect request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.ResetPassword(r.Context(), request.Token, request.Password); err != nil {
+		var invalid *validation.Error
+		switch {
+		case errors.As(err, &invalid):
+			validationError(w, invalid)
+		case err == services.ErrInvalidResetToken:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// changePassword keeps the session of the refresh token cookie alive when keep_session is set
+func (h *Handler) changePassword(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+		KeepSession     bool   `json:"keep_session"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		http.Error(w, "incorrect request body", http.StatusBadRequest)
+		return
+	}
+	keep := ""
+	if request.KeepSession {
+		if cookie, err := r.Cookie(middlewares.RefreshCookie); err == nil {
+			keep = cookie.Value
+		}
+	}
+	claims := r.Context().Value(middlewares.CtxClaims{}).(models.Claims)
+	err := h.service.ChangePassword(r.Context(), claims.UserId, request.CurrentPassword, request.NewPassword, keep)
+	if err != nil {
+		var invalid *validation.Error
+		switch {
+		case errors.As(err, &invalid):
+			validationError(w, invalid)
+		case err == services.ErrIncorrectPassword:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case err == services.ErrUserNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,6 +2,7 @@ package v1
 
 import (
 	"github.com/d1mitrii/authentication-service/internal/controller/http/middlewares"
+	"github.com/d1mitrii/authentication-service/internal/models"
 	"github.com/d1mitrii/authentication-service/internal/services"
 	"net/http"
 
@@ -27,8 +28,13 @@ func (h *Handler) Routes() chi.Router {
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {})
 	r.Post("/signup", h.signUp)
 	r.Post("/login", h.logIn)
+	r.Get("/verify-email", h.verifyEmailPage)
+	r.Post("/verify-email", h.verifyEmail)
+	r.Post("/verify-email/resend", h.resendVerification)
+	r.Post("/password/forgot", h.forgotPassword)
+	r.Post("/password/reset", h.resetPassword)
 
-	auth := middlewares.NewAuthMiddleware(h.service.JWT)
+	auth := middlewares.NewAuthMiddleware(h.service)
 
 	r.Group(func(r chi.Router) {
 		r.Use(auth.RefreshTokenCookie)
@@ -39,6 +45,18 @@ func (h *Handler) Routes() chi.Router {
 	r.Group(func(r chi.Router) {
 		r.Use(auth.JWT)
 		r.Get("/secret", h.secret)
+		r.Post("/password/change", h.changePassword)
+
+		r.Get("/sessions", h.listSessions)
+		r.Delete("/sessions", h.revokeAllSessions)
+		r.Delete("/sessions/{sessionId}", h.revokeSession)
+
+		r.Route("/users/{userId}/sessions", func(r chi.Router) {
+			r.Use(auth.RequireRole(models.RoleAdmin, models.RoleSupport))
+			r.Get("/", h.listSessions)
+			r.Delete("/", h.revokeAllSessions)
+			r.Delete("/{sessionId}", h.revokeSession)
+		})
 	})
 
 	return r
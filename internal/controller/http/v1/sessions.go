@@ -0,0 +1,77 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/d1mitrii/authentication-service/internal/controller/http/middlewares"
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *Handler) listSessions(w http.ResponseWriter, r *http.Request) {
+	userId, ok := sessionOwner(w, r)
+	if !ok {
+		return
+	}
+	sessions, err := h.service.ListSessions(r.Context(), userId)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	type response struct {
+		Sessions []models.RefreshSession `json:"sessions"`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response{sessions})
+}
+
+func (h *Handler) revokeSession(w http.ResponseWriter, r *http.Request) {
+	userId, ok := sessionOwner(w, r)
+	if !ok {
+		return
+	}
+	err := h.service.RevokeSession(r.Context(), userId, chi.URLParam(r, "sessionId"))
+	if err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) revokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userId, ok := sessionOwner(w, r)
+	if !ok {
+		return
+	}
+	if h.service.RevokeAllSessions(r.Context(), userId) != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionOwner returns the user whose sessions are managed:
+// the user of the path on support routes, the caller otherwise
+func sessionOwner(w http.ResponseWriter, r *http.Request) (int, bool) {
+	param := chi.URLParam(r, "userId")
+	if len(param) == 0 {
+		claims := r.Context().Value(middlewares.CtxClaims{}).(models.Claims)
+		return claims.UserId, true
+	}
+	userId, err := strconv.Atoi(param)
+	if err != nil {
+		http.Error(w, "incorrect user id", http.StatusBadRequest)
+		return 0, false
+	}
+	return userId, true
+}
@@ -0,0 +1,102 @@
+package v1
+
+import (
+	"encoding/json"
+	"github.com/d1mitrii/authentication-service/internal/services"
+	"html/template"
+	"net/http"
+)
+
+// verifyPage asks the user to confirm, opening the link alone doesn't use the token up:
+// mail scanners that follow links would burn it before the user clicks.
+// The form posts back to the same URL, token included, with an empty body.
+var verifyPage = template.Must(template.New("verify").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="robots" content="noindex"><title>Verify your email</title></head>
+<body>
+{{if .Done}}<p>Your email is verified, you can close this page.</p>
+{{else if .Failed}}<p>The link is invalid or expired, ask for a new one.</p>
+{{else}}<form method="post">
+<p>Confirm that this is your email address.</p>
+<button type="submit">Verify email</button>
+</form>
+{{end}}</body>
+</html>
+`))
+
+type verifyPageData struct {
+	Done   bool
+	Failed bool
+}
+
+// verifyEmailPage is the target of the emailed link, it only renders the confirmation form
+func (h *Handler) verifyEmailPage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("token") == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+	renderVerifyPage(w, http.StatusOK, verifyPageData{})
+}
+
+// verifyEmail takes the token from the "token" query parameter, as posted by the confirmation form,
+// or from a JSON body. Form posts get a page back, other callers a status.
+func (h *Handler) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	fromForm := r.Header.Get("Content-Type") == "application/x-www-form-urlencoded"
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		var request struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "incorrect request body", http.StatusBadRequest)
+			return
+		}
+		token = request.Token
+	}
+	if token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.VerifyEmail(r.Context(), token); err != nil {
+		if err == services.ErrInvalidVerificationToken {
+			if fromForm {
+				renderVerifyPage(w, http.StatusBadRequest, verifyPageData{Failed: true})
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if fromForm {
+		renderVerifyPage(w, http.StatusOK, verifyPageData{Done: true})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func renderVerifyPage(w http.ResponseWriter, status int, data verifyPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(status)
+	verifyPage.Execute(w, data)
+}
+
+// resendVerification answers 202 whether or not the email belongs to an account
+func (h *Handler) resendVerification(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Email == "" {
+		http.Error(w, "incorrect request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.ResendVerification(r.Context(), request.Email); err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -1,8 +1,11 @@
 package converter
 
 import (
+	"errors"
 	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/services"
 	desc "github.com/d1mitrii/authentication-service/pkg/auth/v1"
+	"strconv"
 )
 
 // Convert api login request to user model for service layer
@@ -19,16 +22,104 @@ func LoginReqToUserModel(data *desc.LoginRequest) (models.User, error) {
 	}, nil
 }
 
-// Convert api register request to user model for service layer
-func RegisterReqToUserModel(data *desc.RegisterRequest) (models.User, error) {
-	if len(data.Email) == 0 {
-		return models.User{}, ErrEmptyEmail
-	}
-	if len(data.Password) == 0 {
-		return models.User{}, ErrEmptyPassword
-	}
+// Convert api register request to user model for service layer,
+// the fields are validated by the service
+func RegisterReqToUserModel(data *desc.RegisterRequest) models.User {
 	return models.User{
 		Email:    data.Email,
 		Password: data.Password,
-	}, nil
+	}
+}
+
+// Convert key set from service layer to api response
+func JWKSToResponse(set models.JWKSet) *desc.JWKS {
+	keys := make([]*desc.JWK, 0, len(set.Keys))
+	for _, key := range set.Keys {
+		keys = append(keys, &desc.JWK{
+			Kty: key.Kty,
+			Kid: key.Kid,
+			Alg: key.Alg,
+			Use: key.Use,
+			N:   key.N,
+			E:   key.E,
+			Crv: key.Crv,
+			X:   key.X,
+			Y:   key.Y,
+		})
+	}
+	return &desc.JWKS{Keys: keys}
+}
+
+// Convert token introspection result to api response
+func IntrospectionToResponse(result models.Introspection) *desc.IntrospectResponse {
+	if !result.Active {
+		return &desc.IntrospectResponse{}
+	}
+	response := &desc.IntrospectResponse{
+		Active:    true,
+		TokenType: result.TokenType,
+		Subject:   strconv.Itoa(result.UserId),
+		Username:  result.Email,
+		Scope:     result.Scope,
+		Issuer:    result.Issuer,
+		Audience:  result.Audience,
+		TokenId:   result.ID,
+	}
+	if !result.ExpiresAt.IsZero() {
+		response.ExpiresAt = result.ExpiresAt.Unix()
+	}
+	if !result.IssuedAt.IsZero() {
+		response.IssuedAt = result.IssuedAt.Unix()
+	}
+	return response
+}
+
+// Convert verified access token claims to api response
+func ClaimsToValidateResponse(claims models.Claims) *desc.ValidateTokenResponse {
+	return &desc.ValidateTokenResponse{
+		Valid:     true,
+		UserId:    int64(claims.UserId),
+		Email:     claims.Email,
+		Roles:     claims.Roles,
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		TokenId:   claims.ID,
+	}
+}
+
+// Convert access token rejection error from service layer to api reason
+func RejectionReason(err error) desc.TokenRejectionReason {
+	switch {
+	case errors.Is(err, services.ErrTokenMalformed):
+		return desc.TokenRejectionReason_TOKEN_REJECTION_REASON_MALFORMED
+	case errors.Is(err, services.ErrTokenExpired):
+		return desc.TokenRejectionReason_TOKEN_REJECTION_REASON_EXPIRED
+	case errors.Is(err, services.ErrTokenBadSignature):
+		return desc.TokenRejectionReason_TOKEN_REJECTION_REASON_BAD_SIGNATURE
+	case errors.Is(err, services.ErrTokenRevoked):
+		return desc.TokenRejectionReason_TOKEN_REJECTION_REASON_REVOKED
+	case errors.Is(err, services.ErrTokenInvalidClaims):
+		return desc.TokenRejectionReason_TOKEN_REJECTION_REASON_INVALID_CLAIMS
+	default:
+		return desc.TokenRejectionReason_TOKEN_REJECTION_REASON_UNSPECIFIED
+	}
+}
+
+// Convert sessions from service layer to api response
+func SessionsToResponse(sessions []models.RefreshSession) *desc.ListSessionsResponse {
+	response := &desc.ListSessionsResponse{
+		Sessions: make([]*desc.Session, 0, len(sessions)),
+	}
+	for _, session := range sessions {
+		response.Sessions = append(response.Sessions, &desc.Session{
+			Id:         session.Id,
+			CreatedAt:  session.CreatedAt.Unix(),
+			LastUsedAt: session.LastUsedAt.Unix(),
+			ExpiresAt:  session.ExpiresAt.Unix(),
+			Ip:         session.IP,
+			UserAgent:  session.UserAgent,
+			DeviceName: session.DeviceName,
+			Persistent: session.Persistent,
+		})
+	}
+	return response
 }
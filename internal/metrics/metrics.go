@@ -11,6 +11,8 @@ type Metrics struct {
 	grpcDuration     *prometheus.HistogramVec
 	httpRequestTotal *prometheus.CounterVec
 	httpDuration     *prometheus.HistogramVec
+	sessionsEvicted  *prometheus.CounterVec
+	sessionsRejected prometheus.Counter
 }
 
 var metrics *Metrics
@@ -64,6 +66,21 @@ func Init(reg prometheus.Registerer) error {
 			},
 			[]string{"status", "method", "path"},
 		),
+		sessionsEvicted: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "sessions_evicted_total",
+				Help:      "Sessions ended to make room for a new login",
+			},
+			[]string{"policy"},
+		),
+		sessionsRejected: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "sessions_rejected_total",
+				Help:      "Logins rejected because of the session limit",
+			},
+		),
 	}
 
 	reg.MustRegister(
@@ -71,6 +88,8 @@ func Init(reg prometheus.Registerer) error {
 		metrics.grpcDuration,
 		metrics.httpRequestTotal,
 		metrics.httpDuration,
+		metrics.sessionsEvicted,
+		metrics.sessionsRejected,
 	)
 
 	return nil
@@ -91,3 +110,11 @@ func HttpCounterRequestTotal(status string, method string, path string) {
 func HttpHistogramResponseTimeObserve(status string, method string, path string, time float64) {
 	metrics.httpDuration.WithLabelValues(status, method, path).Observe(time)
 }
+
+func SessionsEvictedTotal(policy string, count int) {
+	metrics.sessionsEvicted.WithLabelValues(policy).Add(float64(count))
+}
+
+func SessionsRejectedTotal() {
+	metrics.sessionsRejected.Inc()
+}
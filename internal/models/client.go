@@ -0,0 +1,8 @@
+package models
+
+// ClientInfo describes who is calling: taken from HTTP headers or gRPC peer and metadata
+type ClientInfo struct {
+	IP         string
+	UserAgent  string
+	DeviceName string
+}
@@ -1,6 +1,75 @@
 package models
 
+import (
+	"slices"
+	"time"
+)
+
 type Token struct {
 	Access  string `json:"accessToken"`
 	Refresh string `json:"refreshToken"`
+	// RefreshExpiresAt is when the session of the refresh token ends
+	RefreshExpiresAt time.Time `json:"-"`
+	// Persistent is false when the refresh token should be forgotten with the browser session
+	Persistent bool `json:"-"`
+}
+
+// Claims is the verified content of an access token
+type Claims struct {
+	UserId    int
+	Email     string
+	Roles     []string
+	SessionId string
+	ID        string
+	Issuer    string
+	Subject   string
+	Audience  []string
+	IssuedAt  time.Time
+	NotBefore time.Time
+	ExpiresAt time.Time
+}
+
+// HasRole reports whether the token grants any of the roles
+func (c Claims) HasRole(roles ...string) bool {
+	return slices.ContainsFunc(c.Roles, func(role string) bool {
+		return slices.Contains(roles, role)
+	})
+}
+
+const (
+	TokenTypeAccess  = "access_token"
+	TokenTypeRefresh = "refresh_token"
+)
+
+// Introspection describes the state of a token (RFC 7662)
+type Introspection struct {
+	Active    bool
+	TokenType string
+	UserId    int
+	Email     string
+	// Scope holds the roles of an access token, space separated
+	Scope     string
+	ID        string
+	Issuer    string
+	Audience  []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// JWK is a public verification key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet is a JSON Web Key Set document
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
 }
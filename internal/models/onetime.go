@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Purposes of one-time tokens
+const (
+	PurposeEmailVerification = "email_verification"
+	PurposePasswordReset     = "password_reset"
+)
+
+// OneTimeToken is a single-use token sent to the user, only its digest is stored
+type OneTimeToken struct {
+	Purpose   string    `json:"purpose"`
+	UserId    int       `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
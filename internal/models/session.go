@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// RefreshSession is the state kept for a refresh token.
+// Every login starts a new session, refreshing rotates the token within the session
+// and keeps its id, so the id names a logged in device rather than a single token.
+// CreatedAt is the time of the login and bounds the lifetime of the whole session,
+// client fields are those of the last login or refresh.
+type RefreshSession struct {
+	Id         string    `json:"id"`
+	UserId     int       `json:"user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	DeviceName string    `json:"device_name,omitempty"`
+	// Persistent sessions were started with "remember me" and get the long idle timeout
+	Persistent bool      `json:"persistent"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
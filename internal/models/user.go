@@ -2,9 +2,28 @@ package models
 
 import "time"
 
+const (
+	RoleAdmin   = "admin"
+	RoleSupport = "support"
+)
+
 type User struct {
 	Id        int       `db:"id"`
 	Email     string    `json:"email" db:"email"`
 	Password  string    `json:"password" db:"password"`
 	CreatedAt time.Time `db:"created_at"`
+	Roles     []string  `json:"-" db:"roles"`
+	// EmailVerifiedAt is nil until the user follows the verification link
+	EmailVerifiedAt *time.Time `json:"-" db:"email_verified_at"`
+}
+
+func (u User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// UserInfo holds standard OpenID Connect claims of the user
+type UserInfo struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
 }
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/repository"
+)
+
+type denylistEntry struct {
+	revoked bool
+	// before is the revocation time of a subject
+	before  time.Time
+	expires time.Time
+}
+
+// TokenDenylist keeps denylist lookups in process memory, so checking
+// an access token doesn't cost a round trip to the store on every request.
+// Answers are cached for ttl, which bounds how long a revocation made
+// by another instance of the service may go unnoticed.
+type TokenDenylist struct {
+	repo        repository.TokenDenylistRepo
+	ttl         time.Duration
+	mu          sync.Mutex
+	entries     map[string]denylistEntry
+	revocations map[string]denylistEntry
+	nextSweep   time.Time
+	now         func() time.Time
+}
+
+func NewTokenDenylist(repo repository.TokenDenylistRepo, ttl time.Duration) *TokenDenylist {
+	return &TokenDenylist{
+		repo:        repo,
+		ttl:         ttl,
+		entries:     make(map[string]denylistEntry),
+		revocations: make(map[string]denylistEntry),
+		now:         time.Now,
+	}
+}
+
+func (c *TokenDenylist) Add(ctx context.Context, jti string, ttl time.Duration) error {
+	if err := c.repo.Add(ctx, jti, ttl); err != nil {
+		return err
+	}
+	c.store(c.entries, jti, denylistEntry{revoked: true, expires: c.now().Add(ttl)})
+	return nil
+}
+
+func (c *TokenDenylist) Contains(ctx context.Context, jti string) (bool, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[jti]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := c.repo.Contains(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+	c.store(c.entries, jti, denylistEntry{revoked: revoked, expires: c.now().Add(c.ttl)})
+	return revoked, nil
+}
+
+func (c *TokenDenylist) RevokeBefore(ctx context.Context, subject string, before time.Time, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	if err := c.repo.RevokeBefore(ctx, subject, before, ttl); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	entry, ok := c.revocations[subject]
+	c.mu.Unlock()
+	if ok && entry.before.After(before) {
+		before = entry.before
+	}
+	c.store(c.revocations, subject, denylistEntry{before: before, expires: c.now().Add(c.ttl)})
+	return nil
+}
+
+func (c *TokenDenylist) RevokedBefore(ctx context.Context, subject string) (time.Time, error) {
+	c.mu.Lock()
+	entry, ok := c.revocations[subject]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		return entry.before, nil
+	}
+
+	before, err := c.repo.RevokedBefore(ctx, subject)
+	if err != nil {
+		return time.Time{}, err
+	}
+	c.store(c.revocations, subject, denylistEntry{before: before, expires: c.now().Add(c.ttl)})
+	return before, nil
+}
+
+func (c *TokenDenylist) store(entries map[string]denylistEntry, key string, entry denylistEntry) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if now.After(c.nextSweep) {
+		for _, m := range []map[string]denylistEntry{c.entries, c.revocations} {
+			for key, e := range m {
+				if now.After(e.expires) {
+					delete(m, key)
+				}
+			}
+		}
+		c.nextSweep = now.Add(c.ttl)
+	}
+	entries[key] = entry
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/repository"
+	"github.com/d1mitrii/authentication-service/internal/repository/memory"
+	"github.com/d1mitrii/authentication-service/internal/repository/repotest"
+)
+
+func TestTokenDenylist(t *testing.T) {
+	repotest.Denylist(t, func(t *testing.T) (repository.TokenDenylistRepo, repotest.Advance) {
+		// expiry is up to the store, the cache only forgets its answers
+		return NewTokenDenylist(memory.NewTokenDenylist(), time.Minute), nil
+	})
+}
+
+// TestRevocationByAnotherInstance writes to the store directly, as another instance of the service would.
+// The cache answers from memory until its ttl runs out.
+func TestRevocationByAnotherInstance(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := memory.NewTokenDenylist()
+	cache := NewTokenDenylist(store, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	if revoked, err := cache.Contains(ctx, "jti"); err != nil || revoked {
+		t.Fatalf("Contains() = %v, %v, want false", revoked, err)
+	}
+	if before, err := cache.RevokedBefore(ctx, "user:1"); err != nil || !before.IsZero() {
+		t.Fatalf("RevokedBefore() = %v, %v, want the zero time", before, err)
+	}
+	revokedAt := time.Now()
+	if err := store.Add(ctx, "jti", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeBefore(ctx, "user:1", revokedAt, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if revoked, _ := cache.Contains(ctx, "jti"); revoked {
+		t.Error("Contains() within the cache ttl saw the change")
+	}
+	now = now.Add(time.Minute + time.Second)
+	if revoked, err := cache.Contains(ctx, "jti"); err != nil || !revoked {
+		t.Errorf("Contains() after the cache ttl = %v, %v, want true", revoked, err)
+	}
+	if before, err := cache.RevokedBefore(ctx, "user:1"); err != nil || !before.Equal(revokedAt) {
+		t.Errorf("RevokedBefore() after the cache ttl = %v, %v, want %v", before, err, revokedAt)
+	}
+}
+
+func TestNoCaching(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewTokenDenylist()
+	cache := NewTokenDenylist(store, 0)
+	if revoked, err := cache.Contains(ctx, "jti"); err != nil || revoked {
+		t.Fatalf("Contains() = %v, %v, want false", revoked, err)
+	}
+	if err := store.Add(ctx, "jti", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := cache.Contains(ctx, "jti"); err != nil || !revoked {
+		t.Errorf("Contains() without a cache ttl = %v, %v, want true", revoked, err)
+	}
+}
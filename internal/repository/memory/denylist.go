@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type revocation struct {
+	before  time.Time
+	expires time.Time
+}
+
+type TokenDenylist struct {
+	mu          sync.Mutex
+	entries     map[string]time.Time
+	revocations map[string]revocation
+	nextSweep   time.Time
+	now         func() time.Time
+}
+
+func NewTokenDenylist() *TokenDenylist {
+	return &TokenDenylist{
+		entries:     make(map[string]time.Time),
+		revocations: make(map[string]revocation),
+		now:         time.Now,
+	}
+}
+
+// Add denylists the token id, the entry expires together with the token
+func (r *TokenDenylist) Add(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	r.sweep(now)
+	r.entries[jti] = now.Add(ttl)
+	return nil
+}
+
+func (r *TokenDenylist) Contains(ctx context.Context, jti string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	expires, ok := r.entries[jti]
+	return ok && expires.After(r.now()), nil
+}
+
+func (r *TokenDenylist) RevokeBefore(ctx context.Context, subject string, before time.Time, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	r.sweep(now)
+	entry := r.revocations[subject]
+	if before.After(entry.before) {
+		entry.before = before
+	}
+	if expires := now.Add(ttl); expires.After(entry.expires) {
+		entry.expires = expires
+	}
+	r.revocations[subject] = entry
+	return nil
+}
+
+func (r *TokenDenylist) RevokedBefore(ctx context.Context, subject string) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.revocations[subject]
+	if !ok || !entry.expires.After(r.now()) {
+		return time.Time{}, nil
+	}
+	return entry.before, nil
+}
+
+// sweep drops expired entries once per sweepInterval, r.mu must be held
+func (r *TokenDenylist) sweep(now time.Time) {
+	if now.Before(r.nextSweep) {
+		return
+	}
+	for key, expires := range r.entries {
+		if !expires.After(now) {
+			delete(r.entries, key)
+		}
+	}
+	for key, entry := range r.revocations {
+		if !entry.expires.After(now) {
+			delete(r.revocations, key)
+		}
+	}
+	r.nextSweep = now.Add(sweepInterval)
+}
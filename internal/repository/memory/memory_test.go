@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/repository"
+	"github.com/d1mitrii/authentication-service/internal/repository/repotest"
+)
+
+// fakeTime replaces the clock of a repository, the returned function moves it forward
+func fakeTime(now *func() time.Time) repotest.Advance {
+	current := time.Now()
+	*now = func() time.Time { return current }
+	return func(d time.Duration) { current = current.Add(d) }
+}
+
+func TestRefreshSession(t *testing.T) {
+	repotest.RefreshSessions(t, func(t *testing.T) (repository.RefreshSessionRepo, repotest.Advance) {
+		repo := NewRefreshRepo(repotest.RefreshTTL)
+		return repo, fakeTime(&repo.now)
+	})
+}
+
+func TestTokenDenylist(t *testing.T) {
+	repotest.Denylist(t, func(t *testing.T) (repository.TokenDenylistRepo, repotest.Advance) {
+		repo := NewTokenDenylist()
+		return repo, fakeTime(&repo.now)
+	})
+}
+
+func TestUserRepo(t *testing.T) {
+	repotest.Users(t, func(t *testing.T) repository.UserRepo {
+		return NewUserRepo()
+	})
+}
+
+func TestOneTimeTokenRepo(t *testing.T) {
+	repotest.OneTimeTokens(t, func(t *testing.T) (repository.OneTimeTokenRepo, repotest.Advance) {
+		repo := NewOneTimeTokenRepo()
+		return repo, fakeTime(&repo.now)
+	})
+}
+
+func TestSweep(t *testing.T) {
+	ctx := context.Background()
+	sessions := NewRefreshRepo(repotest.RefreshTTL)
+	advance := fakeTime(&sessions.now)
+	session := repotest.Session("a", 1, sessions.now())
+	if err := sessions.CreateSession(ctx, repotest.Digest("a1"), session); err != nil {
+		t.Fatal(err)
+	}
+	if err := sessions.MarkSpent(ctx, repotest.Digest("a0"), session); err != nil {
+		t.Fatal(err)
+	}
+	denylist := NewTokenDenylist()
+	denylist.now = sessions.now
+	if err := denylist.Add(ctx, "jti", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	advance(repotest.RefreshTTL + time.Hour)
+	session = repotest.Session("b", 2, sessions.now())
+	if err := sessions.CreateSession(ctx, repotest.Digest("b1"), session); err != nil {
+		t.Fatal(err)
+	}
+	if err := denylist.Add(ctx, "other", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions.tokens) != 1 || len(sessions.live) != 1 || len(sessions.users) != 1 || len(sessions.spent) != 0 {
+		t.Errorf("after the sweep %d tokens, %d pointers, %d users, %d spent marks, want only the new session",
+			len(sessions.tokens), len(sessions.live), len(sessions.users), len(sessions.spent))
+	}
+	if len(denylist.entries) != 1 {
+		t.Errorf("after the sweep %d denylist entries, want 1", len(denylist.entries))
+	}
+}
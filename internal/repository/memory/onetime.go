@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
+)
+
+type OneTimeTokenRepo struct {
+	mu        sync.Mutex
+	tokens    map[string]models.OneTimeToken
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+func NewOneTimeTokenRepo() *OneTimeTokenRepo {
+	return &OneTimeTokenRepo{
+		tokens: make(map[string]models.OneTimeToken),
+		now:    time.Now,
+	}
+}
+
+func (r *OneTimeTokenRepo) CreateToken(ctx context.Context, digest string, token models.OneTimeToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	if now.After(r.nextSweep) {
+		for key, token := range r.tokens {
+			if !token.ExpiresAt.After(now) {
+				delete(r.tokens, key)
+			}
+		}
+		r.nextSweep = now.Add(sweepInterval)
+	}
+	r.tokens[digest] = token
+	return nil
+}
+
+func (r *OneTimeTokenRepo) GetToken(ctx context.Context, purpose string, digest string) (models.OneTimeToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[digest]
+	if !ok || token.Purpose != purpose || !token.ExpiresAt.After(r.now()) {
+		return models.OneTimeToken{}, repoerrors.ErrNotFound
+	}
+	return token, nil
+}
+
+func (r *OneTimeTokenRepo) ConsumeToken(ctx context.Context, purpose string, digest string) (models.OneTimeToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[digest]
+	if !ok || token.Purpose != purpose {
+		return models.OneTimeToken{}, repoerrors.ErrNotFound
+	}
+	delete(r.tokens, digest)
+	if !token.ExpiresAt.After(r.now()) {
+		return models.OneTimeToken{}, repoerrors.ErrNotFound
+	}
+	return token, nil
+}
+
+func (r *OneTimeTokenRepo) DeleteUserTokens(ctx context.Context, userId int, purpose string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, token := range r.tokens {
+		if token.UserId == userId && token.Purpose == purpose {
+			delete(r.tokens, key)
+		}
+	}
+	return nil
+}
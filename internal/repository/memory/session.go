@@ -0,0 +1,190 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
+)
+
+type spentEntry struct {
+	session models.RefreshSession
+	expires time.Time
+}
+
+// RefreshSession mirrors the Redis layout in process memory: sessions keyed by token digest,
+// a pointer from every session id to its live token, spent marks and a set of session ids per user.
+// Expired entries are invisible to reads and swept out on writes.
+type RefreshSession struct {
+	mu          sync.Mutex
+	refresh_ttl time.Duration
+	tokens      map[string]models.RefreshSession
+	live        map[string]string
+	spent       map[string]spentEntry
+	users       map[int]map[string]struct{}
+	nextSweep   time.Time
+	now         func() time.Time
+}
+
+func NewRefreshRepo(refresh_ttl time.Duration) *RefreshSession {
+	return &RefreshSession{
+		refresh_ttl: refresh_ttl,
+		tokens:      make(map[string]models.RefreshSession),
+		live:        make(map[string]string),
+		spent:       make(map[string]spentEntry),
+		users:       make(map[int]map[string]struct{}),
+		now:         time.Now,
+	}
+}
+
+func (r *RefreshSession) CreateSession(ctx context.Context, digest string, session models.RefreshSession) error {
+	const op = "RefreshSession.CreateSession"
+	now := r.now()
+	if !session.ExpiresAt.After(now) {
+		return fmt.Errorf("%s: session already expired", op)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sweep(now)
+	r.tokens[digest] = session
+	r.live[session.Id] = digest
+	if r.users[session.UserId] == nil {
+		r.users[session.UserId] = make(map[string]struct{})
+	}
+	r.users[session.UserId][session.Id] = struct{}{}
+	return nil
+}
+
+func (r *RefreshSession) GetSession(ctx context.Context, digest string) (models.RefreshSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.tokens[digest]
+	if !ok || !session.ExpiresAt.After(r.now()) {
+		return models.RefreshSession{}, repoerrors.ErrNotFound
+	}
+	return session, nil
+}
+
+func (r *RefreshSession) DeleteSession(ctx context.Context, digest string) (models.RefreshSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.tokens[digest]
+	if !ok || !session.ExpiresAt.After(r.now()) {
+		return models.RefreshSession{}, repoerrors.ErrNotFound
+	}
+	delete(r.tokens, digest)
+	return session, nil
+}
+
+func (r *RefreshSession) RotateSession(ctx context.Context, oldDigest string, newDigest string, session models.RefreshSession) error {
+	const op = "RefreshSession.RotateSession"
+	now := r.now()
+	if !session.ExpiresAt.After(now) {
+		return fmt.Errorf("%s: session already expired", op)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.tokens[oldDigest]
+	if !ok || !current.ExpiresAt.After(now) || r.live[session.Id] != oldDigest {
+		return repoerrors.ErrNotFound
+	}
+	delete(r.tokens, oldDigest)
+	r.tokens[newDigest] = session
+	r.live[session.Id] = newDigest
+	return nil
+}
+
+func (r *RefreshSession) MarkSpent(ctx context.Context, digest string, session models.RefreshSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spent[digest] = spentEntry{session: session, expires: r.now().Add(r.refresh_ttl)}
+	return nil
+}
+
+func (r *RefreshSession) GetSpent(ctx context.Context, digest string) (models.RefreshSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.spent[digest]
+	if !ok || !entry.expires.After(r.now()) {
+		return models.RefreshSession{}, repoerrors.ErrNotFound
+	}
+	return entry.session, nil
+}
+
+func (r *RefreshSession) ListSessions(ctx context.Context, userId int) ([]models.RefreshSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	sessions := []models.RefreshSession{}
+	for id := range r.users[userId] {
+		session, ok := r.tokens[r.live[id]]
+		if ok && session.ExpiresAt.After(now) {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *RefreshSession) RevokeSession(ctx context.Context, userId int, sessionId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[userId][sessionId]; !ok {
+		return repoerrors.ErrNotFound
+	}
+	r.revoke(userId, sessionId)
+	return nil
+}
+
+func (r *RefreshSession) RevokeAllSessions(ctx context.Context, userId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id := range r.users[userId] {
+		r.revoke(userId, id)
+	}
+	return nil
+}
+
+func (r *RefreshSession) revoke(userId int, sessionId string) {
+	delete(r.tokens, r.live[sessionId])
+	delete(r.live, sessionId)
+	delete(r.users[userId], sessionId)
+	if len(r.users[userId]) == 0 {
+		delete(r.users, userId)
+	}
+}
+
+// sweep drops expired entries at most once per minute, r.mu must be held
+func (r *RefreshSession) sweep(now time.Time) {
+	if now.Before(r.nextSweep) {
+		return
+	}
+	r.nextSweep = now.Add(sweepInterval)
+	for digest, session := range r.tokens {
+		if !session.ExpiresAt.After(now) {
+			delete(r.tokens, digest)
+		}
+	}
+	for id, digest := range r.live {
+		if _, ok := r.tokens[digest]; !ok {
+			delete(r.live, id)
+		}
+	}
+	for userId, ids := range r.users {
+		for id := range ids {
+			if _, ok := r.live[id]; !ok {
+				delete(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			delete(r.users, userId)
+		}
+	}
+	for digest, entry := range r.spent {
+		if !entry.expires.After(now) {
+			delete(r.spent, digest)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
+)
+
+// UserRepo keeps users in process memory, the data is lost on restart
+type UserRepo struct {
+	mu      sync.RWMutex
+	lastId  int
+	users   map[int]models.User
+	byEmail map[string]int
+}
+
+func NewUserRepo() *UserRepo {
+	return &UserRepo{
+		users:   make(map[int]models.User),
+		byEmail: make(map[string]int),
+	}
+}
+
+func (r *UserRepo) CreateUser(ctx context.Context, user models.User) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.byEmail[user.Email]; ok {
+		return 0, repoerrors.ErrAlreadyExist
+	}
+	r.lastId++
+	user.Id = r.lastId
+	user.CreatedAt = time.Now()
+	user.Roles = slices.Clone(user.Roles)
+	r.users[user.Id] = user
+	r.byEmail[user.Email] = user.Id
+	return user.Id, nil
+}
+
+func (r *UserRepo) GetUserById(ctx context.Context, id int) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	user, ok := r.users[id]
+	if !ok {
+		return models.User{}, repoerrors.ErrNotFound
+	}
+	user.Roles = slices.Clone(user.Roles)
+	return user, nil
+}
+
+func (r *UserRepo) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	r.mu.RLock()
+	id, ok := r.byEmail[email]
+	r.mu.RUnlock()
+	if !ok {
+		return models.User{}, repoerrors.ErrNotFound
+	}
+	return r.GetUserById(ctx, id)
+}
+
+func (r *UserRepo) DeleteUser(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return repoerrors.ErrNotFound
+	}
+	delete(r.users, id)
+	delete(r.byEmail, user.Email)
+	return nil
+}
+
+func (r *UserRepo) UpdatePassword(ctx context.Context, userId int, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[userId]
+	if !ok {
+		return repoerrors.ErrNotFound
+	}
+	user.Password = hash
+	r.users[userId] = user
+	return nil
+}
+
+func (r *UserRepo) MarkEmailVerified(ctx context.Context, userId int, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[userId]
+	if !ok {
+		return repoerrors.ErrNotFound
+	}
+	if user.EmailVerifiedAt == nil {
+		user.EmailVerifiedAt = &at
+		r.users[userId] = user
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/d1mitrii/authentication-service/pkg/postgres"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type TokenDenylist struct {
+	*postgres.Postgres
+}
+
+func NewTokenDenylist(pg *postgres.Postgres) *TokenDenylist {
+	return &TokenDenylist{pg}
+}
+
+// Add denylists the token id, the entry expires together with the token
+func (r *TokenDenylist) Add(ctx context.Context, jti string, ttl time.Duration) error {
+	const op = "TokenDenylist.Add"
+	if ttl <= 0 {
+		return nil
+	}
+	sql := `INSERT INTO token_denylist (jti, expires_at) VALUES ($1, $2)
+		ON CONFLICT (jti) DO UPDATE SET expires_at = EXCLUDED.expires_at;`
+	if _, err := r.Pool.Exec(ctx, sql, jti, time.Now().Add(ttl)); err != nil {
+		return fmt.Errorf("%s - r.Pool.Exec: %v", op, err)
+	}
+	return nil
+}
+
+func (r *TokenDenylist) Contains(ctx context.Context, jti string) (bool, error) {
+	const op = "TokenDenylist.Contains"
+	sql := `SELECT EXISTS (SELECT 1 FROM token_denylist WHERE jti = $1 AND expires_at > NOW());`
+	var revoked bool
+	if err := r.Pool.QueryRow(ctx, sql, jti).Scan(&revoked); err != nil {
+		return false, fmt.Errorf("%s - r.Pool.QueryRow: %v", op, err)
+	}
+	return revoked, nil
+}
+
+func (r *TokenDenylist) RevokeBefore(ctx context.Context, subject string, before time.Time, ttl time.Duration) error {
+	const op = "TokenDenylist.RevokeBefore"
+	if ttl <= 0 {
+		return nil
+	}
+	sql := `INSERT INTO token_revocations (subject, revoked_before, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (subject) DO UPDATE SET
+			revoked_before = GREATEST(token_revocations.revoked_before, EXCLUDED.revoked_before),
+			expires_at = GREATEST(token_revocations.expires_at, EXCLUDED.expires_at);`
+	if _, err := r.Pool.Exec(ctx, sql, subject, before, time.Now().Add(ttl)); err != nil {
+		return fmt.Errorf("%s - r.Pool.Exec: %v", op, err)
+	}
+	return nil
+}
+
+func (r *TokenDenylist) RevokedBefore(ctx context.Context, subject string) (time.Time, error) {
+	const op = "TokenDenylist.RevokedBefore"
+	sql := `SELECT revoked_before FROM token_revocations WHERE subject = $1 AND expires_at > NOW();`
+	var before time.Time
+	if err := r.Pool.QueryRow(ctx, sql, subject).Scan(&before); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("%s - r.Pool.QueryRow: %v", op, err)
+	}
+	return before, nil
+}
+
+// DeleteExpired removes entries of tokens that expired anyway
+func (r *TokenDenylist) DeleteExpired(ctx context.Context) (int64, error) {
+	const op = "TokenDenylist.DeleteExpired"
+	var deleted int64
+	for _, sql := range []string{
+		`DELETE FROM token_denylist WHERE expires_at <= NOW();`,
+		`DELETE FROM token_revocations WHERE expires_at <= NOW();`,
+	} {
+		tag, err := r.Pool.Exec(ctx, sql)
+		if err != nil {
+			return deleted, fmt.Errorf("%s - r.Pool.Exec: %v", op, err)
+		}
+		deleted += tag.RowsAffected()
+	}
+	return deleted, nil
+}
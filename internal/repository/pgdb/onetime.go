@@ -0,0 +1,78 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
+	"github.com/d1mitrii/authentication-service/pkg/postgres"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type OneTimeTokenRepo struct {
+	*postgres.Postgres
+}
+
+func NewOneTimeTokenRepo(pg *postgres.Postgres) *OneTimeTokenRepo {
+	return &OneTimeTokenRepo{pg}
+}
+
+func (r *OneTimeTokenRepo) CreateToken(ctx context.Context, digest string, token models.OneTimeToken) error {
+	const op = "OneTimeTokenRepo.CreateToken"
+	sql := `INSERT INTO one_time_tokens (digest, purpose, user_id, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5);`
+	_, err := r.Pool.Exec(ctx, sql, digest, token.Purpose, token.UserId, token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s - r.Pool.Exec: %v", op, err)
+	}
+	return nil
+}
+
+func (r *OneTimeTokenRepo) GetToken(ctx context.Context, purpose string, digest string) (models.OneTimeToken, error) {
+	const op = "OneTimeTokenRepo.GetToken"
+	sql := `SELECT (purpose, user_id, created_at, expires_at) FROM one_time_tokens
+		WHERE digest = $1 AND purpose = $2 AND expires_at > NOW();`
+	var token models.OneTimeToken
+	if err := r.Pool.QueryRow(ctx, sql, digest, purpose).Scan(&token); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.OneTimeToken{}, repoerrors.ErrNotFound
+		}
+		return models.OneTimeToken{}, fmt.Errorf("%s - r.Pool.QueryRow: %v", op, err)
+	}
+	return token, nil
+}
+
+func (r *OneTimeTokenRepo) ConsumeToken(ctx context.Context, purpose string, digest string) (models.OneTimeToken, error) {
+	const op = "OneTimeTokenRepo.ConsumeToken"
+	sql := `DELETE FROM one_time_tokens WHERE digest = $1 AND purpose = $2 AND expires_at > NOW()
+		RETURNING (purpose, user_id, created_at, expires_at);`
+	var token models.OneTimeToken
+	if err := r.Pool.QueryRow(ctx, sql, digest, purpose).Scan(&token); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.OneTimeToken{}, repoerrors.ErrNotFound
+		}
+		return models.OneTimeToken{}, fmt.Errorf("%s - r.Pool.QueryRow: %v", op, err)
+	}
+	return token, nil
+}
+
+func (r *OneTimeTokenRepo) DeleteUserTokens(ctx context.Context, userId int, purpose string) error {
+	const op = "OneTimeTokenRepo.DeleteUserTokens"
+	sql := `DELETE FROM one_time_tokens WHERE user_id = $1 AND purpose = $2;`
+	if _, err := r.Pool.Exec(ctx, sql, userId, purpose); err != nil {
+		return fmt.Errorf("%s - r.Pool.Exec: %v", op, err)
+	}
+	return nil
+}
+
+// DeleteExpired removes tokens that can no longer be used
+func (r *OneTimeTokenRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	const op = "OneTimeTokenRepo.DeleteExpired"
+	tag, err := r.Pool.Exec(ctx, `DELETE FROM one_time_tokens WHERE expires_at <= NOW();`)
+	if err != nil {
+		return 0, fmt.Errorf("%s - r.Pool.Exec: %v", op, err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,104 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/repository"
+	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
+	"github.com/d1mitrii/authentication-service/internal/repository/repotest"
+	"github.com/d1mitrii/authentication-service/pkg/postgres"
+)
+
+// openDB connects to TEST_POSTGRES_URL, a database migrated with migrations/ whose data the tests delete.
+// Without it the tests are skipped.
+func openDB(t *testing.T) *postgres.Postgres {
+	t.Helper()
+	url := os.Getenv("TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("TEST_POSTGRES_URL is not set")
+	}
+	pg, err := postgres.New(url, postgres.MaxPoolSize(4), postgres.ConnAttempts(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pg.Close)
+	_, err = pg.Pool.Exec(context.Background(), `TRUNCATE users, refresh_sessions, refresh_spent,
+		token_denylist, token_revocations, one_time_tokens RESTART IDENTITY CASCADE;`)
+	if err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	return pg
+}
+
+// openDBWithUsers adds users 1 and 2, sessions and tokens reference them
+func openDBWithUsers(t *testing.T) *postgres.Postgres {
+	t.Helper()
+	pg := openDB(t)
+	users := NewUserRepo(pg)
+	for _, email := range []string{"ann@example.com", "bob@example.com"} {
+		if _, err := users.CreateUser(context.Background(), models.User{Email: email, Password: "hash"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return pg
+}
+
+func TestRefreshSessionRepo(t *testing.T) {
+	repotest.RefreshSessions(t, func(t *testing.T) (repository.RefreshSessionRepo, repotest.Advance) {
+		return NewRefreshSessionRepo(openDBWithUsers(t), repotest.RefreshTTL), nil
+	})
+}
+
+func TestTokenDenylist(t *testing.T) {
+	repotest.Denylist(t, func(t *testing.T) (repository.TokenDenylistRepo, repotest.Advance) {
+		return NewTokenDenylist(openDB(t)), nil
+	})
+}
+
+func TestUserRepo(t *testing.T) {
+	repotest.Users(t, func(t *testing.T) repository.UserRepo {
+		return NewUserRepo(openDB(t))
+	})
+}
+
+func TestOneTimeTokenRepo(t *testing.T) {
+	repotest.OneTimeTokens(t, func(t *testing.T) (repository.OneTimeTokenRepo, repotest.Advance) {
+		return NewOneTimeTokenRepo(openDBWithUsers(t)), nil
+	})
+}
+
+// TestExpiredRows stores rows that expired already, the queries must not see them
+// and DeleteExpired must remove them
+func TestExpiredRows(t *testing.T) {
+	ctx := context.Background()
+	pg := openDBWithUsers(t)
+	past := time.Now().Add(-2 * time.Hour)
+
+	// a negative refresh ttl makes spent marks expire at once
+	sessions := NewRefreshSessionRepo(pg, -time.Hour)
+	session := repotest.Session("a", 1, past)
+	if err := sessions.CreateSession(ctx, repotest.Digest("a1"), session); err != nil {
+		t.Fatal(err)
+	}
+	if err := sessions.MarkSpent(ctx, repotest.Digest("a0"), session); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sessions.GetSession(ctx, repotest.Digest("a1")); !errors.Is(err, repoerrors.ErrNotFound) {
+		t.Errorf("GetSession() of an expired session error = %v", err)
+	}
+	if _, err := sessions.GetSpent(ctx, repotest.Digest("a0")); !errors.Is(err, repoerrors.ErrNotFound) {
+		t.Errorf("GetSpent() of an expired mark error = %v", err)
+	}
+	rotated := repotest.Session("a", 1, time.Now())
+	if err := sessions.RotateSession(ctx, repotest.Digest("a1"), repotest.Digest("a2"), rotated); !errors.Is(err, repoerrors.ErrNotFound) {
+		t.Errorf("RotateSession() of an expired session error = %v", err)
+	}
+	if deleted, err := sessions.DeleteExpired(ctx); err != nil || deleted != 2 {
+		t.Errorf("DeleteExpired() = %d, %v, want the session and the mark", deleted, err)
+	}
+}
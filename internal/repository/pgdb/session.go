@@ -0,0 +1,196 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
+	"github.com/d1mitrii/authentication-service/pkg/postgres"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const sessionColumns = `session_id, user_id, created_at, last_used_at, ip, user_agent, device_name, persistent, expires_at`
+
+// RefreshSessionRepo keeps one row per session holding the digest of its live token,
+// rotated tokens are kept in refresh_spent for refresh_ttl.
+// Expired rows are ignored by every query and removed by DeleteExpired.
+type RefreshSessionRepo struct {
+	*postgres.Postgres
+	refresh_ttl time.Duration
+}
+
+func NewRefreshSessionRepo(pg *postgres.Postgres, refresh_ttl time.Duration) *RefreshSessionRepo {
+	return &RefreshSessionRepo{
+		Postgres:    pg,
+		refresh_ttl: refresh_ttl,
+	}
+}
+
+func (r *RefreshSessionRepo) CreateSession(ctx context.Context, digest string, session models.RefreshSession) error {
+	const op = "RefreshSessionRepo.CreateSession"
+	sql := `INSERT INTO refresh_sessions (digest, ` + sessionColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);`
+	_, err := r.Pool.Exec(ctx, sql, digest,
+		session.Id,
+		session.UserId,
+		session.CreatedAt,
+		session.LastUsedAt,
+		session.IP,
+		session.UserAgent,
+		session.DeviceName,
+		session.Persistent,
+		session.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s - r.Pool.Exec: %v", op, err)
+	}
+	return nil
+}
+
+func (r *RefreshSessionRepo) GetSession(ctx context.Context, digest string) (models.RefreshSession, error) {
+	const op = "RefreshSessionRepo.GetSession"
+	sql := `SELECT (` + sessionColumns + `) FROM refresh_sessions WHERE digest = $1 AND expires_at > NOW();`
+	var session models.RefreshSession
+	err := r.Pool.QueryRow(ctx, sql, digest).Scan(&session)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.RefreshSession{}, repoerrors.ErrNotFound
+		}
+		return models.RefreshSession{}, fmt.Errorf("%s - r.Pool.QueryRow: %v", op, err)
+	}
+	return session, nil
+}
+
+// DeleteSession removes the row and returns it in one statement,
+// so a token can be exchanged only once even by concurrent requests
+func (r *RefreshSessionRepo) DeleteSession(ctx context.Context, digest string) (models.RefreshSession, error) {
+	const op = "RefreshSessionRepo.DeleteSession"
+	sql := `DELETE FROM refresh_sessions WHERE digest = $1 AND expires_at > NOW() RETURNING (` + sessionColumns + `);`
+	var session models.RefreshSession
+	err := r.Pool.QueryRow(ctx, sql, digest).Scan(&session)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.RefreshSession{}, repoerrors.ErrNotFound
+		}
+		return models.RefreshSession{}, fmt.Errorf("%s - r.Pool.QueryRow: %v", op, err)
+	}
+	return session, nil
+}
+
+// RotateSession swaps the digest only while the row still holds the old one,
+// a revoked session has no row left to update and stays revoked
+func (r *RefreshSessionRepo) RotateSession(ctx context.Context, oldDigest string, newDigest string, session models.RefreshSession) error {
+	const op = "RefreshSessionRepo.RotateSession"
+	sql := `UPDATE refresh_sessions SET
+			digest = $1,
+			last_used_at = $2,
+			ip = $3,
+			user_agent = $4,
+			device_name = $5,
+			expires_at = $6
+		WHERE session_id = $7 AND digest = $8 AND expires_at > NOW();`
+	tag, err := r.Pool.Exec(ctx, sql, newDigest,
+		session.LastUsedAt,
+		session.IP,
+		session.UserAgent,
+		session.DeviceName,
+		session.ExpiresAt,
+		session.Id,
+		oldDigest,
+	)
+	if err != nil {
+		return fmt.Errorf("%s - r.Pool.Exec: %v", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+	return nil
+}
+
+func (r *RefreshSessionRepo) MarkSpent(ctx context.Context, digest string, session models.RefreshSession) error {
+	const op = "RefreshSessionRepo.MarkSpent"
+	sql := `INSERT INTO refresh_spent (digest, session_id, user_id, ip, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (digest) DO NOTHING;`
+	_, err := r.Pool.Exec(ctx, sql, digest,
+		session.Id,
+		session.UserId,
+		session.IP,
+		session.UserAgent,
+		time.Now().Add(r.refresh_ttl),
+	)
+	if err != nil {
+		return fmt.Errorf("%s - r.Pool.Exec: %v", op, err)
+	}
+	return nil
+}
+
+func (r *RefreshSessionRepo) GetSpent(ctx context.Context, digest string) (models.RefreshSession, error) {
+	const op = "RefreshSessionRepo.GetSpent"
+	sql := `SELECT session_id, user_id, ip, user_agent FROM refresh_spent WHERE digest = $1 AND expires_at > NOW();`
+	var session models.RefreshSession
+	err := r.Pool.QueryRow(ctx, sql, digest).Scan(&session.Id, &session.UserId, &session.IP, &session.UserAgent)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.RefreshSession{}, repoerrors.ErrNotFound
+		}
+		return models.RefreshSession{}, fmt.Errorf("%s - r.Pool.QueryRow: %v", op, err)
+	}
+	return session, nil
+}
+
+func (r *RefreshSessionRepo) ListSessions(ctx context.Context, userId int) ([]models.RefreshSession, error) {
+	const op = "RefreshSessionRepo.ListSessions"
+	sql := `SELECT (` + sessionColumns + `) FROM refresh_sessions WHERE user_id = $1 AND expires_at > NOW();`
+	rows, err := r.Pool.Query(ctx, sql, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s - r.Pool.Query: %v", op, err)
+	}
+	sessions, err := pgx.CollectRows(rows, pgx.RowTo[models.RefreshSession])
+	if err != nil {
+		return nil, fmt.Errorf("%s - pgx.CollectRows: %v", op, err)
+	}
+	return sessions, nil
+}
+
+func (r *RefreshSessionRepo) RevokeSession(ctx context.Context, userId int, sessionId string) error {
+	const op = "RefreshSessionRepo.RevokeSession"
+	sql := `DELETE FROM refresh_sessions WHERE user_id = $1 AND session_id = $2;`
+	tag, err := r.Pool.Exec(ctx, sql, userId, sessionId)
+	if err != nil {
+		return fmt.Errorf("%s - r.Pool.Exec: %v", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+	return nil
+}
+
+func (r *RefreshSessionRepo) RevokeAllSessions(ctx context.Context, userId int) error {
+	const op = "RefreshSessionRepo.RevokeAllSessions"
+	sql := `DELETE FROM refresh_sessions WHERE user_id = $1;`
+	if _, err := r.Pool.Exec(ctx, sql, userId); err != nil {
+		return fmt.Errorf("%s - r.Pool.Exec: %v", op, err)
+	}
+	return nil
+}
+
+// DeleteExpired removes expired sessions and spent marks, returns the number of removed rows
+func (r *RefreshSessionRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	const op = "RefreshSessionRepo.DeleteExpired"
+	var deleted int64
+	for _, sql := range []string{
+		`DELETE FROM refresh_sessions WHERE expires_at <= NOW();`,
+		`DELETE FROM refresh_spent WHERE expires_at <= NOW();`,
+	} {
+		tag, err := r.Pool.Exec(ctx, sql)
+		if err != nil {
+			return deleted, fmt.Errorf("%s - r.Pool.Exec: %v", op, err)
+		}
+		deleted += tag.RowsAffected()
+	}
+	return deleted, nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/d1mitrii/authentication-service/internal/models"
 	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
 	"github.com/d1mitrii/authentication-service/pkg/postgres"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -39,7 +40,7 @@ func (r *UserRepo) CreateUser(ctx context.Context, user models.User) (int, error
 
 func (r *UserRepo) GetUserById(ctx context.Context, id int) (models.User, error) {
 	const op = "UserRepo.GetUserById"
-	sql := `SELECT (id, email, password, created_at) FROM users WHERE id = $1;`
+	sql := `SELECT (id, email, password, created_at, roles, email_verified_at) FROM users WHERE id = $1;`
 	var user models.User
 	err := r.Pool.QueryRow(ctx, sql, id).Scan(&user)
 	if err != nil {
@@ -53,7 +54,7 @@ func (r *UserRepo) GetUserById(ctx context.Context, id int) (models.User, error)
 
 func (r *UserRepo) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
 	const op = "UserRepo.GetUserByEmail"
-	sql := `SELECT (id, email, password, created_at) FROM users WHERE email = $1;`
+	sql := `SELECT (id, email, password, created_at, roles, email_verified_at) FROM users WHERE email = $1;`
 	var user models.User
 	err := r.Pool.QueryRow(ctx, sql, email).Scan(&user)
 	if err != nil {
@@ -77,3 +78,29 @@ func (r *UserRepo) DeleteUser(ctx context.Context, id int) error {
 	}
 	return nil
 }
+
+func (r *UserRepo) UpdatePassword(ctx context.Context, userId int, hash string) error {
+	const op = "UserRepo.UpdatePassword"
+	sql := `UPDATE users SET password = $2 WHERE id = $1;`
+	tag, err := r.Pool.Exec(ctx, sql, userId, hash)
+	if err != nil {
+		return fmt.Errorf("%s - r.Pool.Exec: %v", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+	return nil
+}
+
+func (r *UserRepo) MarkEmailVerified(ctx context.Context, userId int, at time.Time) error {
+	const op = "UserRepo.MarkEmailVerified"
+	sql := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, $2) WHERE id = $1;`
+	tag, err := r.Pool.Exec(ctx, sql, userId, at)
+	if err != nil {
+		return fmt.Errorf("%s - r.Pool.Exec: %v", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repoerrors.ErrNotFound
+	}
+	return nil
+}
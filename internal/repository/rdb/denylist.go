@@ -0,0 +1,76 @@
+package rdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	denylistPrefix    = "denylist:"
+	revocationsPrefix = "revoked:"
+)
+
+type TokenDenylist struct {
+	client            redis.UniversalClient
+	prefix            string
+	revocationsPrefix string
+}
+
+// NewTokenDenylist stores entries under keyPrefix, so several deployments can share one Redis
+func NewTokenDenylist(client redis.UniversalClient, keyPrefix string) *TokenDenylist {
+	return &TokenDenylist{
+		client:            client,
+		prefix:            keyPrefix + denylistPrefix,
+		revocationsPrefix: keyPrefix + revocationsPrefix,
+	}
+}
+
+// Add denylists the token id, the entry expires together with the token
+func (r *TokenDenylist) Add(ctx context.Context, jti string, ttl time.Duration) error {
+	const op = "TokenDenylist.Add"
+	if ttl <= 0 {
+		return nil
+	}
+	if err := r.client.Set(ctx, r.prefix+jti, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("%s - client.Set: %v", op, err)
+	}
+	return nil
+}
+
+func (r *TokenDenylist) Contains(ctx context.Context, jti string) (bool, error) {
+	const op = "TokenDenylist.Contains"
+	n, err := r.client.Exists(ctx, r.prefix+jti).Result()
+	if err != nil {
+		return false, fmt.Errorf("%s - client.Exists: %v", op, err)
+	}
+	return n > 0, nil
+}
+
+// RevokeBefore stores the time as unix nanoseconds. Callers pass the current time,
+// so a later call never moves it back.
+func (r *TokenDenylist) RevokeBefore(ctx context.Context, subject string, before time.Time, ttl time.Duration) error {
+	const op = "TokenDenylist.RevokeBefore"
+	if ttl <= 0 {
+		return nil
+	}
+	if err := r.client.Set(ctx, r.revocationsPrefix+subject, before.UnixNano(), ttl).Err(); err != nil {
+		return fmt.Errorf("%s - client.Set: %v", op, err)
+	}
+	return nil
+}
+
+func (r *TokenDenylist) RevokedBefore(ctx context.Context, subject string) (time.Time, error) {
+	const op = "TokenDenylist.RevokedBefore"
+	nanos, err := r.client.Get(ctx, r.revocationsPrefix+subject).Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("%s - client.Get: %v", op, err)
+	}
+	return time.Unix(0, nanos), nil
+}
@@ -0,0 +1,94 @@
+package rdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/repository"
+	"github.com/d1mitrii/authentication-service/internal/repository/repotest"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+const testPrefix = "test:"
+
+// newRedis starts an in-process Redis, its clock moves only with FastForward
+func newRedis(t *testing.T) (*miniredis.Miniredis, redis.UniversalClient) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func TestRefreshSession(t *testing.T) {
+	repotest.RefreshSessions(t, func(t *testing.T) (repository.RefreshSessionRepo, repotest.Advance) {
+		server, client := newRedis(t)
+		return NewRefreshRepo(client, testPrefix, repotest.RefreshTTL), server.FastForward
+	})
+}
+
+func TestTokenDenylist(t *testing.T) {
+	repotest.Denylist(t, func(t *testing.T) (repository.TokenDenylistRepo, repotest.Advance) {
+		server, client := newRedis(t)
+		return NewTokenDenylist(client, testPrefix), server.FastForward
+	})
+}
+
+// TestKeysOfUserShareSlot checks that every key of a user but the owner keys carries the user's hash tag,
+// a Redis Cluster runs the transactions only on keys of one slot
+func TestKeysOfUserShareSlot(t *testing.T) {
+	ctx := context.Background()
+	server, client := newRedis(t)
+	repo := NewRefreshRepo(client, testPrefix, repotest.RefreshTTL)
+	session := repotest.Session("a", 1, time.Now())
+	if err := repo.CreateSession(ctx, repotest.Digest("a1"), session); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MarkSpent(ctx, repotest.Digest("a0"), session); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RotateSession(ctx, repotest.Digest("a1"), repotest.Digest("a2"), session); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := server.Keys()
+	if len(keys) == 0 {
+		t.Fatal("no keys written")
+	}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, testPrefix) {
+			t.Errorf("key %q without the prefix", key)
+		}
+		if strings.HasPrefix(key, testPrefix+ownerPrefix) {
+			continue
+		}
+		if !strings.HasPrefix(key, testPrefix+"refresh:{1}:") {
+			t.Errorf("key %q outside the slot of user 1", key)
+		}
+	}
+	if server.Exists(repo.key(1, repotest.Digest("a1"))) {
+		t.Error("the record of the rotated token is left behind")
+	}
+}
+
+// TestListSessionsDropsExpiredIds checks that ids whose token expired are removed from the user set
+func TestListSessionsDropsExpiredIds(t *testing.T) {
+	ctx := context.Background()
+	server, client := newRedis(t)
+	repo := NewRefreshRepo(client, testPrefix, repotest.RefreshTTL)
+	if err := repo.CreateSession(ctx, repotest.Digest("a1"), repotest.Session("a", 1, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	server.FastForward(2 * time.Hour)
+	sessions, err := repo.ListSessions(ctx, 1)
+	if err != nil || len(sessions) != 0 {
+		t.Fatalf("ListSessions() = %+v, %v, want none", sessions, err)
+	}
+	if ids, _ := server.Members(repo.key(1, sessionsKey)); len(ids) != 0 {
+		t.Errorf("user set = %v, want the expired id removed", ids)
+	}
+}
@@ -2,47 +2,387 @@ package rdb
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"github.com/d1mitrii/authentication-service/internal/models"
 	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	ownerPrefix   = "refresh:owner:"
+	sessionsKey   = "sessions"
+	pointerPrefix = "session:"
+	spentPrefix   = "spent:"
+
+	// maxTxRetries bounds the retries of a transaction whose watched keys were changed meanwhile
+	maxTxRetries = 5
+)
+
+// RefreshSession stores sessions under the SHA-256 digest of the refresh token,
+// raw tokens never reach Redis.
+// Each session id points to the digest of its live token, rotated tokens are kept as spent,
+// and every user has a set with the ids of their sessions.
+// A session expires at its ExpiresAt, refresh_ttl is the longest a session may stay unused.
+// All keys of one user share the hash tag {<user id>}, so they live in one slot of a Redis Cluster
+// and every change to them is a single MULTI transaction. The user of a digest is found
+// through an owner key outside the slot, tokens don't carry the user id.
 type RefreshSession struct {
-	client      *redis.Client
+	client      redis.UniversalClient
+	prefix      string
 	refresh_ttl time.Duration
 }
 
-func NewRefreshRepo(client *redis.Client, refresh_ttl time.Duration) *RefreshSession {
+// NewRefreshRepo stores keys under keyPrefix, so several deployments can share one Redis
+func NewRefreshRepo(client redis.UniversalClient, keyPrefix string, refresh_ttl time.Duration) *RefreshSession {
 	return &RefreshSession{
 		client:      client,
+		prefix:      keyPrefix,
 		refresh_ttl: refresh_ttl,
 	}
 }
 
-func (r *RefreshSession) CreateSession(ctx context.Context, refreshToken string, id int) error {
-	return r.client.Set(ctx, refreshToken, id, r.refresh_ttl).Err()
+// key returns the key of the user, e.g. refresh:{42}:spent:<digest>
+func (r *RefreshSession) key(userId int, name string) string {
+	return r.prefix + "refresh:{" + strconv.Itoa(userId) + "}:" + name
 }
 
-func (r *RefreshSession) GetSession(ctx context.Context, refreshToken string) (int, error) {
-	const op = "RefreshSession.GetSession"
-	id, err := r.client.Get(ctx, refreshToken).Int()
+func (r *RefreshSession) ownerKey(digest string) string {
+	return r.prefix + ownerPrefix + digest
+}
+
+// owner returns the id of the user the token of the digest was issued to
+func (r *RefreshSession) owner(ctx context.Context, digest string) (int, error) {
+	userId, err := r.client.Get(ctx, r.ownerKey(digest)).Int()
 	if err == redis.Nil {
 		return 0, repoerrors.ErrNotFound
 	} else if err != nil {
-		return 0, fmt.Errorf("%s - client.Get: %v", op, err)
+		return 0, fmt.Errorf("client.Get: %v", err)
 	}
-	return id, nil
+	return userId, nil
 }
 
-func (r *RefreshSession) DeleteSession(ctx context.Context, refreshToken string) (int, error) {
+func (r *RefreshSession) CreateSession(ctx context.Context, digest string, session models.RefreshSession) error {
+	const op = "RefreshSession.CreateSession"
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("%s - json.Marshal: %v", op, err)
+	}
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		return fmt.Errorf("%s: session already expired", op)
+	}
+	// written first, an owner key left by a failed transaction points to nothing
+	if err := r.client.Set(ctx, r.ownerKey(digest), session.UserId, r.refresh_ttl).Err(); err != nil {
+		return fmt.Errorf("%s - client.Set: %v", op, err)
+	}
+	userKey := r.key(session.UserId, sessionsKey)
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.key(session.UserId, digest), data, ttl)
+		pipe.Set(ctx, r.key(session.UserId, pointerPrefix+session.Id), digest, ttl)
+		pipe.SAdd(ctx, userKey, session.Id)
+		pipe.Expire(ctx, userKey, r.refresh_ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s - client.TxPipelined: %v", op, err)
+	}
+	return nil
+}
+
+func (r *RefreshSession) GetSession(ctx context.Context, digest string) (models.RefreshSession, error) {
+	const op = "RefreshSession.GetSession"
+	userId, err := r.owner(ctx, digest)
+	if err == repoerrors.ErrNotFound {
+		return models.RefreshSession{}, err
+	} else if err != nil {
+		return models.RefreshSession{}, fmt.Errorf("%s - %v", op, err)
+	}
+	data, err := r.client.Get(ctx, r.key(userId, digest)).Bytes()
+	if err == redis.Nil {
+		return models.RefreshSession{}, repoerrors.ErrNotFound
+	} else if err != nil {
+		return models.RefreshSession{}, fmt.Errorf("%s - client.Get: %v", op, err)
+	}
+	return unmarshalSession(op, data)
+}
+
+func (r *RefreshSession) DeleteSession(ctx context.Context, digest string) (models.RefreshSession, error) {
 	const op = "RefreshSession.DeleteSession"
-	id, err := r.client.GetDel(ctx, refreshToken).Int()
+	userId, err := r.owner(ctx, digest)
+	if err == repoerrors.ErrNotFound {
+		return models.RefreshSession{}, err
+	} else if err != nil {
+		return models.RefreshSession{}, fmt.Errorf("%s - %v", op, err)
+	}
+	data, err := r.client.GetDel(ctx, r.key(userId, digest)).Bytes()
 	if err == redis.Nil {
-		return 0, repoerrors.ErrNotFound
+		return models.RefreshSession{}, repoerrors.ErrNotFound
+	} else if err != nil {
+		return models.RefreshSession{}, fmt.Errorf("%s - client.GetDel: %v", op, err)
+	}
+	return unmarshalSession(op, data)
+}
+
+// RotateSession watches the pointer of the session and the record of the old token,
+// the swap is made only if the pointer still points to the old token,
+// so a revocation or rotation running meanwhile wins and the session isn't brought back
+func (r *RefreshSession) RotateSession(ctx context.Context, oldDigest string, newDigest string, session models.RefreshSession) error {
+	const op = "RefreshSession.RotateSession"
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("%s - json.Marshal: %v", op, err)
+	}
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		return fmt.Errorf("%s: session already expired", op)
+	}
+	if err := r.client.Set(ctx, r.ownerKey(newDigest), session.UserId, r.refresh_ttl).Err(); err != nil {
+		return fmt.Errorf("%s - client.Set: %v", op, err)
+	}
+	userKey := r.key(session.UserId, sessionsKey)
+	pointer := r.key(session.UserId, pointerPrefix+session.Id)
+	oldKey := r.key(session.UserId, oldDigest)
+	err = r.transaction(ctx, func(tx *redis.Tx) error {
+		live, err := tx.Get(ctx, pointer).Result()
+		if err == redis.Nil {
+			return repoerrors.ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("tx.Get: %v", err)
+		}
+		exists, err := tx.Exists(ctx, oldKey).Result()
+		if err != nil {
+			return fmt.Errorf("tx.Exists: %v", err)
+		}
+		if live != oldDigest || exists == 0 {
+			return repoerrors.ErrNotFound
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, oldKey)
+			pipe.Set(ctx, r.key(session.UserId, newDigest), data, ttl)
+			pipe.Set(ctx, pointer, newDigest, ttl)
+			pipe.SAdd(ctx, userKey, session.Id)
+			pipe.Expire(ctx, userKey, r.refresh_ttl)
+			return nil
+		})
+		return err
+	}, pointer, oldKey)
+	if err == repoerrors.ErrNotFound {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("%s - %v", op, err)
+	}
+	return nil
+}
+
+func (r *RefreshSession) MarkSpent(ctx context.Context, digest string, session models.RefreshSession) error {
+	const op = "RefreshSession.MarkSpent"
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("%s - json.Marshal: %v", op, err)
+	}
+	// the owner key is kept as long as the mark, the keys are in different slots
+	_, err = r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.ownerKey(digest), session.UserId, r.refresh_ttl)
+		pipe.Set(ctx, r.key(session.UserId, spentPrefix+digest), data, r.refresh_ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s - client.Pipelined: %v", op, err)
+	}
+	return nil
+}
+
+func (r *RefreshSession) GetSpent(ctx context.Context, digest string) (models.RefreshSession, error) {
+	const op = "RefreshSession.GetSpent"
+	userId, err := r.owner(ctx, digest)
+	if err == repoerrors.ErrNotFound {
+		return models.RefreshSession{}, err
+	} else if err != nil {
+		return models.RefreshSession{}, fmt.Errorf("%s - %v", op, err)
+	}
+	data, err := r.client.Get(ctx, r.key(userId, spentPrefix+digest)).Bytes()
+	if err == redis.Nil {
+		return models.RefreshSession{}, repoerrors.ErrNotFound
 	} else if err != nil {
-		return 0, fmt.Errorf("%s - client.Get: %v", op, err)
+		return models.RefreshSession{}, fmt.Errorf("%s - client.Get: %v", op, err)
+	}
+	return unmarshalSession(op, data)
+}
+
+// ListSessions resolves every session id of the user to its live token.
+// Ids whose token already expired are dropped from the user set.
+func (r *RefreshSession) ListSessions(ctx context.Context, userId int) ([]models.RefreshSession, error) {
+	const op = "RefreshSession.ListSessions"
+	userKey := r.key(userId, sessionsKey)
+	ids, err := r.client.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("%s - client.SMembers: %v", op, err)
+	}
+	if len(ids) == 0 {
+		return []models.RefreshSession{}, nil
+	}
+
+	digests, err := r.getAll(ctx, r.client, r.pointers(userId, ids))
+	if err != nil {
+		return nil, fmt.Errorf("%s - %v", op, err)
+	}
+	keys := make([]string, 0, len(ids))
+	for _, digest := range digests {
+		if digest != "" {
+			keys = append(keys, r.key(userId, digest))
+		}
+	}
+	records, err := r.getAll(ctx, r.client, keys)
+	if err != nil {
+		return nil, fmt.Errorf("%s - %v", op, err)
+	}
+
+	sessions := make([]models.RefreshSession, 0, len(records))
+	live := make(map[string]bool, len(records))
+	for _, record := range records {
+		if record == "" {
+			continue
+		}
+		session, err := unmarshalSession(op, []byte(record))
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+		live[session.Id] = true
+	}
+
+	var stale []interface{}
+	for _, id := range ids {
+		if !live[id] {
+			stale = append(stale, id)
+		}
+	}
+	if len(stale) != 0 {
+		if err := r.client.SRem(ctx, userKey, stale...).Err(); err != nil {
+			return nil, fmt.Errorf("%s - client.SRem: %v", op, err)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *RefreshSession) RevokeSession(ctx context.Context, userId int, sessionId string) error {
+	const op = "RefreshSession.RevokeSession"
+	userKey := r.key(userId, sessionsKey)
+	pointer := r.key(userId, pointerPrefix+sessionId)
+	var removed *redis.IntCmd
+	err := r.transaction(ctx, func(tx *redis.Tx) error {
+		keys, err := r.liveKeys(ctx, tx, userId, []string{sessionId})
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			removed = pipe.SRem(ctx, userKey, sessionId)
+			pipe.Del(ctx, keys...)
+			return nil
+		})
+		return err
+	}, pointer)
+	if err != nil {
+		return fmt.Errorf("%s - %v", op, err)
+	}
+	if removed.Val() == 0 {
+		return repoerrors.ErrNotFound
+	}
+	return nil
+}
+
+func (r *RefreshSession) RevokeAllSessions(ctx context.Context, userId int) error {
+	const op = "RefreshSession.RevokeAllSessions"
+	userKey := r.key(userId, sessionsKey)
+	err := r.transaction(ctx, func(tx *redis.Tx) error {
+		ids, err := tx.SMembers(ctx, userKey).Result()
+		if err != nil {
+			return fmt.Errorf("tx.SMembers: %v", err)
+		}
+		keys, err := r.liveKeys(ctx, tx, userId, ids)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, append(keys, userKey)...)
+			return nil
+		})
+		return err
+	}, userKey)
+	if err != nil {
+		return fmt.Errorf("%s - %v", op, err)
+	}
+	return nil
+}
+
+// liveKeys watches the pointers of the sessions and returns them together with the keys of their live tokens.
+// A rotation that moves a pointer meanwhile fails the transaction, so no live token is left behind.
+func (r *RefreshSession) liveKeys(ctx context.Context, tx *redis.Tx, userId int, ids []string) ([]string, error) {
+	pointers := r.pointers(userId, ids)
+	if len(pointers) == 0 {
+		return nil, nil
+	}
+	if err := tx.Watch(ctx, pointers...).Err(); err != nil {
+		return nil, fmt.Errorf("tx.Watch: %v", err)
+	}
+	digests, err := r.getAll(ctx, tx, pointers)
+	if err != nil {
+		return nil, err
+	}
+	keys := pointers
+	for _, digest := range digests {
+		if digest != "" {
+			keys = append(keys, r.key(userId, digest))
+		}
+	}
+	return keys, nil
+}
+
+// transaction runs fn with the keys watched and retries it while they change under it
+func (r *RefreshSession) transaction(ctx context.Context, fn func(*redis.Tx) error, keys ...string) error {
+	for i := 0; i < maxTxRetries; i++ {
+		err := r.client.Watch(ctx, fn, keys...)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return fmt.Errorf("client.Watch: %v", redis.TxFailedErr)
+}
+
+func (r *RefreshSession) pointers(userId int, ids []string) []string {
+	pointers := make([]string, len(ids))
+	for i, id := range ids {
+		pointers[i] = r.key(userId, pointerPrefix+id)
+	}
+	return pointers
+}
+
+// getAll reads keys of one user with MGET, missing keys are returned as empty strings
+func (r *RefreshSession) getAll(ctx context.Context, client redis.Cmdable, keys []string) ([]string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	values, err := client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("client.MGet: %v", err)
+	}
+	result := make([]string, len(values))
+	for i, value := range values {
+		if value, ok := value.(string); ok {
+			result[i] = value
+		}
+	}
+	return result, nil
+}
+
+func unmarshalSession(op string, data []byte) (models.RefreshSession, error) {
+	var session models.RefreshSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return models.RefreshSession{}, fmt.Errorf("%s - json.Unmarshal: %v", op, err)
 	}
-	return id, nil
+	return session, nil
 }
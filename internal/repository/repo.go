@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"github.com/d1mitrii/authentication-service/internal/models"
+	"time"
 )
 
 type UserRepo interface {
@@ -10,22 +11,67 @@ type UserRepo interface {
 	GetUserById(context.Context, int) (models.User, error)
 	GetUserByEmail(context.Context, string) (models.User, error)
 	DeleteUser(context.Context, int) error
+	UpdatePassword(ctx context.Context, userId int, hash string) error
+	// MarkEmailVerified records when the email was verified, the first time is kept
+	MarkEmailVerified(ctx context.Context, userId int, at time.Time) error
 }
 
+// OneTimeTokenRepo keeps single-use tokens by the SHA-256 digest of the token
+type OneTimeTokenRepo interface {
+	CreateToken(ctx context.Context, digest string, token models.OneTimeToken) error
+	// GetToken returns a live token without using it up
+	GetToken(ctx context.Context, purpose string, digest string) (models.OneTimeToken, error)
+	// ConsumeToken deletes the token and returns it,
+	// ErrNotFound is returned when there is no live token for the purpose
+	ConsumeToken(ctx context.Context, purpose string, digest string) (models.OneTimeToken, error)
+	// DeleteUserTokens deletes every token of the user for the purpose
+	DeleteUserTokens(ctx context.Context, userId int, purpose string) error
+}
+
+// RefreshSessionRepo keys sessions by the SHA-256 digest of the refresh token
 type RefreshSessionRepo interface {
-	CreateSession(context.Context, string, int) error
-	GetSession(context.Context, string) (int, error)
-	DeleteSession(context.Context, string) (int, error)
+	CreateSession(context.Context, string, models.RefreshSession) error
+	GetSession(context.Context, string) (models.RefreshSession, error)
+	DeleteSession(context.Context, string) (models.RefreshSession, error)
+	// RotateSession moves the session from its live token to a new one in place,
+	// ErrNotFound is returned when oldDigest is no longer the live token of the session:
+	// the session was revoked or rotated by a concurrent request
+	RotateSession(ctx context.Context, oldDigest string, newDigest string, session models.RefreshSession) error
+	// MarkSpent remembers a rotated token and the session it belonged to
+	MarkSpent(ctx context.Context, digest string, session models.RefreshSession) error
+	// GetSpent returns the session of a rotated token
+	GetSpent(ctx context.Context, digest string) (models.RefreshSession, error)
+	// ListSessions returns the live sessions of the user
+	ListSessions(ctx context.Context, userId int) ([]models.RefreshSession, error)
+	// RevokeSession deletes the live token of the session,
+	// ErrNotFound is returned when the user has no such session
+	RevokeSession(ctx context.Context, userId int, sessionId string) error
+	// RevokeAllSessions deletes every live session of the user
+	RevokeAllSessions(ctx context.Context, userId int) error
+}
+
+type TokenDenylistRepo interface {
+	Add(ctx context.Context, jti string, ttl time.Duration) error
+	Contains(ctx context.Context, jti string) (bool, error)
+	// RevokeBefore invalidates the access tokens of the subject (a user or a session) issued before the time.
+	// The entry is kept for ttl, the tokens it covers have expired by then.
+	RevokeBefore(ctx context.Context, subject string, before time.Time, ttl time.Duration) error
+	// RevokedBefore returns the time set for the subject, the zero time when there is none
+	RevokedBefore(ctx context.Context, subject string) (time.Time, error)
 }
 
 type Repositories struct {
 	User           UserRepo
+	OneTimeToken   OneTimeTokenRepo
 	RefreshSession RefreshSessionRepo
+	Denylist       TokenDenylistRepo
 }
 
-func New(users UserRepo, session RefreshSessionRepo) *Repositories {
+func New(users UserRepo, tokens OneTimeTokenRepo, session RefreshSessionRepo, denylist TokenDenylistRepo) *Repositories {
 	return &Repositories{
 		User:           users,
+		OneTimeToken:   tokens,
 		RefreshSession: session,
+		Denylist:       denylist,
 	}
 }
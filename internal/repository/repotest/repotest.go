@@ -0,0 +1,523 @@
+// Package repotest holds the behaviour every storage backend shares,
+// the tests of each backend run these checks against it.
+package repotest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/repository"
+	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
+)
+
+// RefreshTTL is the refresh_ttl session repositories under test are built with
+const RefreshTTL = 24 * time.Hour
+
+// Advance moves the time of a backend forward. Backends whose time can't be moved pass nil
+// and skip the expiry checks.
+type Advance func(time.Duration)
+
+// Digest returns a digest of the form the services store, some schemas fix its length
+func Digest(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])
+}
+
+func newSession(id string, userId int) models.RefreshSession {
+	return Session(id, userId, time.Now())
+}
+
+// Session returns a session of the user that was used at now and expires an hour later
+func Session(id string, userId int, now time.Time) models.RefreshSession {
+	now = now.UTC().Truncate(time.Second)
+	return models.RefreshSession{
+		Id:         id,
+		UserId:     userId,
+		CreatedAt:  now.Add(-time.Minute),
+		LastUsedAt: now,
+		IP:         "192.0.2.1",
+		UserAgent:  "test",
+		DeviceName: "laptop",
+		Persistent: true,
+		ExpiresAt:  now.Add(time.Hour),
+	}
+}
+
+func checkSession(t *testing.T, got, want models.RefreshSession) {
+	t.Helper()
+	if got.Id != want.Id || got.UserId != want.UserId ||
+		got.IP != want.IP || got.UserAgent != want.UserAgent || got.DeviceName != want.DeviceName ||
+		got.Persistent != want.Persistent ||
+		!got.CreatedAt.Equal(want.CreatedAt) || !got.LastUsedAt.Equal(want.LastUsedAt) || !got.ExpiresAt.Equal(want.ExpiresAt) {
+		t.Errorf("session = %+v, want %+v", got, want)
+	}
+}
+
+func checkNotFound(t *testing.T, what string, err error) {
+	t.Helper()
+	if !errors.Is(err, repoerrors.ErrNotFound) {
+		t.Errorf("%s error = %v, want %v", what, err, repoerrors.ErrNotFound)
+	}
+}
+
+func sessionIds(t *testing.T, repo repository.RefreshSessionRepo, userId int) []string {
+	t.Helper()
+	sessions, err := repo.ListSessions(context.Background(), userId)
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	ids := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.Id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// RefreshSessions checks a session repository, open returns an empty one built with RefreshTTL.
+// Sessions belong to users 1 and 2, stores that check foreign keys must have them.
+func RefreshSessions(t *testing.T, open func(t *testing.T) (repository.RefreshSessionRepo, Advance)) {
+	ctx := context.Background()
+	create := func(t *testing.T, repo repository.RefreshSessionRepo, digest string, session models.RefreshSession) {
+		t.Helper()
+		if err := repo.CreateSession(ctx, digest, session); err != nil {
+			t.Fatalf("CreateSession() error = %v", err)
+		}
+	}
+
+	t.Run("create and get", func(t *testing.T) {
+		repo, _ := open(t)
+		session := newSession("a", 1)
+		create(t, repo, Digest("a1"), session)
+		got, err := repo.GetSession(ctx, Digest("a1"))
+		if err != nil {
+			t.Fatalf("GetSession() error = %v", err)
+		}
+		checkSession(t, got, session)
+		_, err = repo.GetSession(ctx, Digest("unknown"))
+		checkNotFound(t, "GetSession() of an unknown digest", err)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		repo, _ := open(t)
+		session := newSession("a", 1)
+		create(t, repo, Digest("a1"), session)
+		got, err := repo.DeleteSession(ctx, Digest("a1"))
+		if err != nil {
+			t.Fatalf("DeleteSession() error = %v", err)
+		}
+		checkSession(t, got, session)
+		_, err = repo.GetSession(ctx, Digest("a1"))
+		checkNotFound(t, "GetSession() after delete", err)
+		_, err = repo.DeleteSession(ctx, Digest("a1"))
+		checkNotFound(t, "second DeleteSession()", err)
+	})
+
+	t.Run("rotate", func(t *testing.T) {
+		repo, _ := open(t)
+		session := newSession("a", 1)
+		create(t, repo, Digest("a1"), session)
+		rotated := session
+		rotated.LastUsedAt = session.LastUsedAt.Add(time.Minute)
+		rotated.ExpiresAt = session.ExpiresAt.Add(time.Minute)
+		rotated.IP = "198.51.100.7"
+		rotated.UserAgent = "other"
+		rotated.DeviceName = "phone"
+		if err := repo.RotateSession(ctx, Digest("a1"), Digest("a2"), rotated); err != nil {
+			t.Fatalf("RotateSession() error = %v", err)
+		}
+		_, err := repo.GetSession(ctx, Digest("a1"))
+		checkNotFound(t, "GetSession() of the rotated token", err)
+		got, err := repo.GetSession(ctx, Digest("a2"))
+		if err != nil {
+			t.Fatalf("GetSession() of the new token error = %v", err)
+		}
+		checkSession(t, got, rotated)
+
+		// a second rotation of the same token lost the race
+		err = repo.RotateSession(ctx, Digest("a1"), Digest("a3"), rotated)
+		checkNotFound(t, "RotateSession() of a rotated token", err)
+		_, err = repo.GetSession(ctx, Digest("a3"))
+		checkNotFound(t, "GetSession() of the losing rotation", err)
+		if ids := sessionIds(t, repo, 1); !slices.Equal(ids, []string{"a"}) {
+			t.Errorf("sessions = %v, want [a]", ids)
+		}
+	})
+
+	t.Run("rotate after revoke", func(t *testing.T) {
+		repo, _ := open(t)
+		session := newSession("a", 1)
+		create(t, repo, Digest("a1"), session)
+		if err := repo.RevokeSession(ctx, 1, "a"); err != nil {
+			t.Fatal(err)
+		}
+		err := repo.RotateSession(ctx, Digest("a1"), Digest("a2"), session)
+		checkNotFound(t, "RotateSession() of a revoked session", err)
+		_, err = repo.GetSession(ctx, Digest("a2"))
+		checkNotFound(t, "GetSession() after rotating a revoked session", err)
+		if ids := sessionIds(t, repo, 1); len(ids) != 0 {
+			t.Errorf("sessions = %v, want the revoked session to stay gone", ids)
+		}
+	})
+
+	t.Run("rotate after delete", func(t *testing.T) {
+		repo, _ := open(t)
+		session := newSession("a", 1)
+		create(t, repo, Digest("a1"), session)
+		if _, err := repo.DeleteSession(ctx, Digest("a1")); err != nil {
+			t.Fatal(err)
+		}
+		err := repo.RotateSession(ctx, Digest("a1"), Digest("a2"), session)
+		checkNotFound(t, "RotateSession() of a deleted token", err)
+		_, err = repo.GetSession(ctx, Digest("a2"))
+		checkNotFound(t, "GetSession() after rotating a deleted token", err)
+	})
+
+	t.Run("spent", func(t *testing.T) {
+		repo, _ := open(t)
+		session := newSession("a", 1)
+		_, err := repo.GetSpent(ctx, Digest("a1"))
+		checkNotFound(t, "GetSpent() of a live token", err)
+		for i := 0; i < 2; i++ {
+			if err := repo.MarkSpent(ctx, Digest("a1"), session); err != nil {
+				t.Fatalf("MarkSpent() error = %v", err)
+			}
+		}
+		got, err := repo.GetSpent(ctx, Digest("a1"))
+		if err != nil {
+			t.Fatalf("GetSpent() error = %v", err)
+		}
+		if got.Id != session.Id || got.UserId != session.UserId || got.IP != session.IP || got.UserAgent != session.UserAgent {
+			t.Errorf("GetSpent() = %+v, want the session %+v", got, session)
+		}
+	})
+
+	t.Run("list and revoke", func(t *testing.T) {
+		repo, _ := open(t)
+		create(t, repo, Digest("a1"), newSession("a", 1))
+		create(t, repo, Digest("b1"), newSession("b", 1))
+		create(t, repo, Digest("c1"), newSession("c", 2))
+		if ids := sessionIds(t, repo, 1); !slices.Equal(ids, []string{"a", "b"}) {
+			t.Errorf("sessions of user 1 = %v, want [a b]", ids)
+		}
+		if ids := sessionIds(t, repo, 3); len(ids) != 0 {
+			t.Errorf("sessions of a user without any = %v", ids)
+		}
+
+		checkNotFound(t, "RevokeSession() of another user's session", repo.RevokeSession(ctx, 2, "a"))
+		if err := repo.RevokeSession(ctx, 1, "a"); err != nil {
+			t.Fatalf("RevokeSession() error = %v", err)
+		}
+		checkNotFound(t, "second RevokeSession()", repo.RevokeSession(ctx, 1, "a"))
+		_, err := repo.GetSession(ctx, Digest("a1"))
+		checkNotFound(t, "GetSession() of a revoked session", err)
+		if ids := sessionIds(t, repo, 1); !slices.Equal(ids, []string{"b"}) {
+			t.Errorf("sessions after revoke = %v, want [b]", ids)
+		}
+
+		if err := repo.RevokeAllSessions(ctx, 1); err != nil {
+			t.Fatalf("RevokeAllSessions() error = %v", err)
+		}
+		if ids := sessionIds(t, repo, 1); len(ids) != 0 {
+			t.Errorf("sessions after revoking all = %v", ids)
+		}
+		_, err = repo.GetSession(ctx, Digest("b1"))
+		checkNotFound(t, "GetSession() after revoking all", err)
+		if ids := sessionIds(t, repo, 2); !slices.Equal(ids, []string{"c"}) {
+			t.Errorf("sessions of user 2 = %v, want [c]", ids)
+		}
+		if err := repo.RevokeAllSessions(ctx, 3); err != nil {
+			t.Errorf("RevokeAllSessions() of a user without sessions error = %v", err)
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		repo, advance := open(t)
+		if advance == nil {
+			t.Skip("the time of the backend can't be moved")
+		}
+		session := newSession("a", 1)
+		create(t, repo, Digest("a1"), session)
+		if err := repo.MarkSpent(ctx, Digest("a0"), session); err != nil {
+			t.Fatal(err)
+		}
+		advance(2 * time.Hour)
+		_, err := repo.GetSession(ctx, Digest("a1"))
+		checkNotFound(t, "GetSession() of an expired session", err)
+		rotated := session
+		rotated.ExpiresAt = session.ExpiresAt.Add(4 * time.Hour)
+		checkNotFound(t, "RotateSession() of an expired session", repo.RotateSession(ctx, Digest("a1"), Digest("a2"), rotated))
+		if ids := sessionIds(t, repo, 1); len(ids) != 0 {
+			t.Errorf("sessions = %v, want the expired one left out", ids)
+		}
+		if _, err := repo.GetSpent(ctx, Digest("a0")); err != nil {
+			t.Errorf("GetSpent() within the refresh ttl error = %v", err)
+		}
+		advance(RefreshTTL)
+		_, err = repo.GetSpent(ctx, Digest("a0"))
+		checkNotFound(t, "GetSpent() past the refresh ttl", err)
+	})
+}
+
+// Denylist checks a denylist repository, open returns an empty one
+func Denylist(t *testing.T, open func(t *testing.T) (repository.TokenDenylistRepo, Advance)) {
+	ctx := context.Background()
+
+	t.Run("add", func(t *testing.T) {
+		repo, _ := open(t)
+		contains := func(jti string) bool {
+			t.Helper()
+			revoked, err := repo.Contains(ctx, jti)
+			if err != nil {
+				t.Fatalf("Contains() error = %v", err)
+			}
+			return revoked
+		}
+		if contains("jti") {
+			t.Error("Contains() of an unknown token = true")
+		}
+		if err := repo.Add(ctx, "jti", time.Hour); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		if !contains("jti") {
+			t.Error("Contains() of a denylisted token = false")
+		}
+		if err := repo.Add(ctx, "expired", 0); err != nil {
+			t.Fatalf("Add() of an expired token error = %v", err)
+		}
+		if contains("expired") {
+			t.Error("Contains() of a token added already expired = true")
+		}
+	})
+
+	t.Run("revoke before", func(t *testing.T) {
+		repo, _ := open(t)
+		revokedBefore := func(subject string) time.Time {
+			t.Helper()
+			before, err := repo.RevokedBefore(ctx, subject)
+			if err != nil {
+				t.Fatalf("RevokedBefore() error = %v", err)
+			}
+			return before
+		}
+		if before := revokedBefore("user:1"); !before.IsZero() {
+			t.Errorf("RevokedBefore() without a revocation = %v, want the zero time", before)
+		}
+		first := time.Now().Truncate(time.Microsecond)
+		if err := repo.RevokeBefore(ctx, "user:1", first, time.Hour); err != nil {
+			t.Fatalf("RevokeBefore() error = %v", err)
+		}
+		if before := revokedBefore("user:1"); !before.Equal(first) {
+			t.Errorf("RevokedBefore() = %v, want %v", before, first)
+		}
+		later := first.Add(time.Second)
+		if err := repo.RevokeBefore(ctx, "user:1", later, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if before := revokedBefore("user:1"); !before.Equal(later) {
+			t.Errorf("RevokedBefore() after a later revocation = %v, want %v", before, later)
+		}
+		if before := revokedBefore("user:2"); !before.IsZero() {
+			t.Errorf("RevokedBefore() of another subject = %v, want the zero time", before)
+		}
+		if err := repo.RevokeBefore(ctx, "user:3", first, 0); err != nil {
+			t.Fatal(err)
+		}
+		if before := revokedBefore("user:3"); !before.IsZero() {
+			t.Errorf("RevokedBefore() of a revocation without ttl = %v, want the zero time", before)
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		repo, advance := open(t)
+		if advance == nil {
+			t.Skip("the time of the backend can't be moved")
+		}
+		if err := repo.Add(ctx, "jti", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.RevokeBefore(ctx, "user:1", time.Now(), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		advance(2 * time.Hour)
+		if revoked, err := repo.Contains(ctx, "jti"); err != nil || revoked {
+			t.Errorf("Contains() of an expired entry = %v, %v, want false", revoked, err)
+		}
+		if before, err := repo.RevokedBefore(ctx, "user:1"); err != nil || !before.IsZero() {
+			t.Errorf("RevokedBefore() of an expired revocation = %v, %v, want the zero time", before, err)
+		}
+	})
+}
+
+// Users checks a user repository, open returns an empty one
+func Users(t *testing.T, open func(t *testing.T) repository.UserRepo) {
+	ctx := context.Background()
+	create := func(t *testing.T, repo repository.UserRepo, email string) int {
+		t.Helper()
+		id, err := repo.CreateUser(ctx, models.User{Email: email, Password: "hash of " + email})
+		if err != nil {
+			t.Fatalf("CreateUser(%q) error = %v", email, err)
+		}
+		return id
+	}
+
+	t.Run("create and get", func(t *testing.T) {
+		repo := open(t)
+		id := create(t, repo, "ann@example.com")
+		other := create(t, repo, "bob@example.com")
+		if id == other {
+			t.Errorf("CreateUser() returned id %d twice", id)
+		}
+		_, err := repo.CreateUser(ctx, models.User{Email: "ann@example.com", Password: "other"})
+		if !errors.Is(err, repoerrors.ErrAlreadyExist) {
+			t.Errorf("CreateUser() of a taken email error = %v, want %v", err, repoerrors.ErrAlreadyExist)
+		}
+
+		user, err := repo.GetUserById(ctx, id)
+		if err != nil {
+			t.Fatalf("GetUserById() error = %v", err)
+		}
+		if user.Id != id || user.Email != "ann@example.com" || user.Password != "hash of ann@example.com" {
+			t.Errorf("GetUserById() = %+v", user)
+		}
+		if user.CreatedAt.IsZero() || user.EmailVerifiedAt != nil {
+			t.Errorf("new user created at %v, verified at %v", user.CreatedAt, user.EmailVerifiedAt)
+		}
+		user, err = repo.GetUserByEmail(ctx, "bob@example.com")
+		if err != nil || user.Id != other {
+			t.Errorf("GetUserByEmail() = %+v, %v, want user %d", user, err, other)
+		}
+		_, err = repo.GetUserById(ctx, other+100)
+		checkNotFound(t, "GetUserById() of an unknown user", err)
+		_, err = repo.GetUserByEmail(ctx, "carol@example.com")
+		checkNotFound(t, "GetUserByEmail() of an unknown email", err)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		repo := open(t)
+		id := create(t, repo, "ann@example.com")
+		if err := repo.UpdatePassword(ctx, id, "new hash"); err != nil {
+			t.Fatalf("UpdatePassword() error = %v", err)
+		}
+		verified := time.Now().UTC().Truncate(time.Second)
+		if err := repo.MarkEmailVerified(ctx, id, verified); err != nil {
+			t.Fatalf("MarkEmailVerified() error = %v", err)
+		}
+		if err := repo.MarkEmailVerified(ctx, id, verified.Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		user, err := repo.GetUserById(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Password != "new hash" {
+			t.Errorf("password = %q, want the new hash", user.Password)
+		}
+		if user.EmailVerifiedAt == nil || !user.EmailVerifiedAt.Equal(verified) {
+			t.Errorf("verified at %v, want the first time %v", user.EmailVerifiedAt, verified)
+		}
+		checkNotFound(t, "UpdatePassword() of an unknown user", repo.UpdatePassword(ctx, id+100, "hash"))
+		checkNotFound(t, "MarkEmailVerified() of an unknown user", repo.MarkEmailVerified(ctx, id+100, verified))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		repo := open(t)
+		id := create(t, repo, "ann@example.com")
+		if err := repo.DeleteUser(ctx, id); err != nil {
+			t.Fatalf("DeleteUser() error = %v", err)
+		}
+		_, err := repo.GetUserById(ctx, id)
+		checkNotFound(t, "GetUserById() of a deleted user", err)
+		_, err = repo.GetUserByEmail(ctx, "ann@example.com")
+		checkNotFound(t, "GetUserByEmail() of a deleted user", err)
+		checkNotFound(t, "second DeleteUser()", repo.DeleteUser(ctx, id))
+		create(t, repo, "ann@example.com")
+	})
+}
+
+// OneTimeTokens checks a one-time token repository, open returns an empty one.
+// Tokens belong to users 1 and 2, stores that check foreign keys must have them.
+func OneTimeTokens(t *testing.T, open func(t *testing.T) (repository.OneTimeTokenRepo, Advance)) {
+	ctx := context.Background()
+	newToken := func(purpose string, userId int) models.OneTimeToken {
+		now := time.Now().UTC().Truncate(time.Second)
+		return models.OneTimeToken{Purpose: purpose, UserId: userId, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	}
+	create := func(t *testing.T, repo repository.OneTimeTokenRepo, digest string, token models.OneTimeToken) {
+		t.Helper()
+		if err := repo.CreateToken(ctx, digest, token); err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+	}
+
+	t.Run("single use", func(t *testing.T) {
+		repo, _ := open(t)
+		token := newToken(models.PurposePasswordReset, 1)
+		create(t, repo, Digest("t"), token)
+		_, err := repo.GetToken(ctx, models.PurposeEmailVerification, Digest("t"))
+		checkNotFound(t, "GetToken() for another purpose", err)
+		_, err = repo.ConsumeToken(ctx, models.PurposeEmailVerification, Digest("t"))
+		checkNotFound(t, "ConsumeToken() for another purpose", err)
+
+		for i := 0; i < 2; i++ {
+			got, err := repo.GetToken(ctx, models.PurposePasswordReset, Digest("t"))
+			if err != nil {
+				t.Fatalf("GetToken() error = %v", err)
+			}
+			if got.UserId != 1 || got.Purpose != token.Purpose || !got.ExpiresAt.Equal(token.ExpiresAt) {
+				t.Errorf("GetToken() = %+v, want %+v", got, token)
+			}
+		}
+		got, err := repo.ConsumeToken(ctx, models.PurposePasswordReset, Digest("t"))
+		if err != nil {
+			t.Fatalf("ConsumeToken() error = %v", err)
+		}
+		if got.UserId != 1 {
+			t.Errorf("ConsumeToken() = %+v, want the token of user 1", got)
+		}
+		_, err = repo.ConsumeToken(ctx, models.PurposePasswordReset, Digest("t"))
+		checkNotFound(t, "second ConsumeToken()", err)
+		_, err = repo.GetToken(ctx, models.PurposePasswordReset, Digest("t"))
+		checkNotFound(t, "GetToken() of a used token", err)
+	})
+
+	t.Run("delete user tokens", func(t *testing.T) {
+		repo, _ := open(t)
+		create(t, repo, Digest("reset-1"), newToken(models.PurposePasswordReset, 1))
+		create(t, repo, Digest("reset-2"), newToken(models.PurposePasswordReset, 1))
+		create(t, repo, Digest("verify"), newToken(models.PurposeEmailVerification, 1))
+		create(t, repo, Digest("other"), newToken(models.PurposePasswordReset, 2))
+		if err := repo.DeleteUserTokens(ctx, 1, models.PurposePasswordReset); err != nil {
+			t.Fatalf("DeleteUserTokens() error = %v", err)
+		}
+		for _, name := range []string{"reset-1", "reset-2"} {
+			_, err := repo.GetToken(ctx, models.PurposePasswordReset, Digest(name))
+			checkNotFound(t, "GetToken() of a deleted token", err)
+		}
+		if _, err := repo.GetToken(ctx, models.PurposeEmailVerification, Digest("verify")); err != nil {
+			t.Errorf("GetToken() for another purpose error = %v", err)
+		}
+		if _, err := repo.GetToken(ctx, models.PurposePasswordReset, Digest("other")); err != nil {
+			t.Errorf("GetToken() of another user error = %v", err)
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		repo, advance := open(t)
+		if advance == nil {
+			t.Skip("the time of the backend can't be moved")
+		}
+		create(t, repo, Digest("t"), newToken(models.PurposePasswordReset, 1))
+		advance(2 * time.Hour)
+		_, err := repo.GetToken(ctx, models.PurposePasswordReset, Digest("t"))
+		checkNotFound(t, "GetToken() of an expired token", err)
+		_, err = repo.ConsumeToken(ctx, models.PurposePasswordReset, Digest("t"))
+		checkNotFound(t, "ConsumeToken() of an expired token", err)
+	})
+}
@@ -0,0 +1,88 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/d1mitrii/authentication-service/pkg/sqlite"
+	"time"
+)
+
+type TokenDenylist struct {
+	*sqlite.SQLite
+}
+
+func NewTokenDenylist(db *sqlite.SQLite) *TokenDenylist {
+	return &TokenDenylist{db}
+}
+
+// Add denylists the token id, the entry expires together with the token
+func (r *TokenDenylist) Add(ctx context.Context, jti string, ttl time.Duration) error {
+	const op = "TokenDenylist.Add"
+	if ttl <= 0 {
+		return nil
+	}
+	sql := `INSERT INTO token_denylist (jti, expires_at) VALUES (?, ?)
+		ON CONFLICT (jti) DO UPDATE SET expires_at = excluded.expires_at;`
+	if _, err := r.DB.ExecContext(ctx, sql, jti, time.Now().Add(ttl).UTC()); err != nil {
+		return fmt.Errorf("%s - r.DB.ExecContext: %v", op, err)
+	}
+	return nil
+}
+
+func (r *TokenDenylist) Contains(ctx context.Context, jti string) (bool, error) {
+	const op = "TokenDenylist.Contains"
+	sql := `SELECT EXISTS (SELECT 1 FROM token_denylist WHERE jti = ? AND expires_at > ?);`
+	var revoked bool
+	if err := r.DB.QueryRowContext(ctx, sql, jti, time.Now().UTC()).Scan(&revoked); err != nil {
+		return false, fmt.Errorf("%s - r.DB.QueryRowContext: %v", op, err)
+	}
+	return revoked, nil
+}
+
+func (r *TokenDenylist) RevokeBefore(ctx context.Context, subject string, before time.Time, ttl time.Duration) error {
+	const op = "TokenDenylist.RevokeBefore"
+	if ttl <= 0 {
+		return nil
+	}
+	sql := `INSERT INTO token_revocations (subject, revoked_before, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (subject) DO UPDATE SET
+			revoked_before = MAX(token_revocations.revoked_before, excluded.revoked_before),
+			expires_at = MAX(token_revocations.expires_at, excluded.expires_at);`
+	if _, err := r.DB.ExecContext(ctx, sql, subject, before.UTC(), time.Now().Add(ttl).UTC()); err != nil {
+		return fmt.Errorf("%s - r.DB.ExecContext: %v", op, err)
+	}
+	return nil
+}
+
+func (r *TokenDenylist) RevokedBefore(ctx context.Context, subject string) (time.Time, error) {
+	const op = "TokenDenylist.RevokedBefore"
+	query := `SELECT revoked_before FROM token_revocations WHERE subject = ? AND expires_at > ?;`
+	var before time.Time
+	if err := r.DB.QueryRowContext(ctx, query, subject, time.Now().UTC()).Scan(&before); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("%s - r.DB.QueryRowContext: %v", op, err)
+	}
+	return before, nil
+}
+
+// DeleteExpired removes entries of tokens that expired anyway
+func (r *TokenDenylist) DeleteExpired(ctx context.Context) (int64, error) {
+	const op = "TokenDenylist.DeleteExpired"
+	var deleted int64
+	for _, query := range []string{
+		`DELETE FROM token_denylist WHERE expires_at <= ?;`,
+		`DELETE FROM token_revocations WHERE expires_at <= ?;`,
+	} {
+		res, err := r.DB.ExecContext(ctx, query, time.Now().UTC())
+		if err != nil {
+			return deleted, fmt.Errorf("%s - r.DB.ExecContext: %v", op, err)
+		}
+		n, _ := res.RowsAffected()
+		deleted += n
+	}
+	return deleted, nil
+}
@@ -0,0 +1,66 @@
+package sqlitedb
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+
+	"github.com/d1mitrii/authentication-service/pkg/sqlite"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrate applies the embedded migrations that weren't applied yet, each in its own transaction.
+// The database file is created and kept up to date by the service itself,
+// so a single binary is all an edge site needs.
+func Migrate(ctx context.Context, db *sqlite.SQLite) error {
+	const op = "sqlitedb.Migrate"
+	_, err := db.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version TEXT PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`)
+	if err != nil {
+		return fmt.Errorf("%s - db.ExecContext: %v", op, err)
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("%s - fs.Glob: %v", op, err)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := apply(ctx, db, path.Base(name), name); err != nil {
+			return fmt.Errorf("%s: %s: %v", op, name, err)
+		}
+	}
+	return nil
+}
+
+func apply(ctx context.Context, db *sqlite.SQLite, version string, name string) error {
+	script, err := migrations.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var applied int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = ?;`, version).Scan(&applied)
+	if err != nil || applied > 0 {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?);`, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,13 @@
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+-- users that signed up before verification existed keep logging in
+UPDATE users SET email_verified_at = CURRENT_TIMESTAMP;
+
+CREATE TABLE one_time_tokens (
+    digest CHAR(64) PRIMARY KEY,
+    purpose TEXT NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+CREATE INDEX one_time_tokens_user_id_idx ON one_time_tokens (user_id, purpose);
+CREATE INDEX one_time_tokens_expires_at_idx ON one_time_tokens (expires_at);
@@ -0,0 +1,77 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
+	"github.com/d1mitrii/authentication-service/pkg/sqlite"
+	"time"
+)
+
+type OneTimeTokenRepo struct {
+	*sqlite.SQLite
+}
+
+func NewOneTimeTokenRepo(db *sqlite.SQLite) *OneTimeTokenRepo {
+	return &OneTimeTokenRepo{db}
+}
+
+func (r *OneTimeTokenRepo) CreateToken(ctx context.Context, digest string, token models.OneTimeToken) error {
+	const op = "OneTimeTokenRepo.CreateToken"
+	sql := `INSERT INTO one_time_tokens (digest, purpose, user_id, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?);`
+	_, err := r.DB.ExecContext(ctx, sql, digest, token.Purpose, token.UserId, token.CreatedAt.UTC(), token.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("%s - r.DB.ExecContext: %v", op, err)
+	}
+	return nil
+}
+
+func (r *OneTimeTokenRepo) GetToken(ctx context.Context, purpose string, digest string) (models.OneTimeToken, error) {
+	const op = "OneTimeTokenRepo.GetToken"
+	query := `SELECT purpose, user_id, created_at, expires_at FROM one_time_tokens
+		WHERE digest = ? AND purpose = ? AND expires_at > ?;`
+	return r.getToken(ctx, op, query, digest, purpose)
+}
+
+func (r *OneTimeTokenRepo) ConsumeToken(ctx context.Context, purpose string, digest string) (models.OneTimeToken, error) {
+	const op = "OneTimeTokenRepo.ConsumeToken"
+	query := `DELETE FROM one_time_tokens WHERE digest = ? AND purpose = ? AND expires_at > ?
+		RETURNING purpose, user_id, created_at, expires_at;`
+	return r.getToken(ctx, op, query, digest, purpose)
+}
+
+func (r *OneTimeTokenRepo) getToken(ctx context.Context, op string, query string, digest string, purpose string) (models.OneTimeToken, error) {
+	var token models.OneTimeToken
+	err := r.DB.QueryRowContext(ctx, query, digest, purpose, time.Now().UTC()).
+		Scan(&token.Purpose, &token.UserId, &token.CreatedAt, &token.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OneTimeToken{}, repoerrors.ErrNotFound
+		}
+		return models.OneTimeToken{}, fmt.Errorf("%s - r.DB.QueryRowContext: %v", op, err)
+	}
+	return token, nil
+}
+
+func (r *OneTimeTokenRepo) DeleteUserTokens(ctx context.Context, userId int, purpose string) error {
+	const op = "OneTimeTokenRepo.DeleteUserTokens"
+	sql := `DELETE FROM one_time_tokens WHERE user_id = ? AND purpose = ?;`
+	if _, err := r.DB.ExecContext(ctx, sql, userId, purpose); err != nil {
+		return fmt.Errorf("%s - r.DB.ExecContext: %v", op, err)
+	}
+	return nil
+}
+
+// DeleteExpired removes tokens that can no longer be used
+func (r *OneTimeTokenRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	const op = "OneTimeTokenRepo.DeleteExpired"
+	res, err := r.DB.ExecContext(ctx, `DELETE FROM one_time_tokens WHERE expires_at <= ?;`, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s - r.DB.ExecContext: %v", op, err)
+	}
+	return res.RowsAffected()
+}
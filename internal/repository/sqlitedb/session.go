@@ -0,0 +1,216 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
+	"github.com/d1mitrii/authentication-service/pkg/sqlite"
+	"time"
+)
+
+const sessionColumns = `session_id, user_id, created_at, last_used_at, ip, user_agent, device_name, persistent, expires_at`
+
+// RefreshSessionRepo has the layout of the pgdb one: a row per session holding the digest
+// of its live token and spent marks in refresh_spent.
+// Times are stored in UTC, so they compare correctly as text.
+type RefreshSessionRepo struct {
+	*sqlite.SQLite
+	refresh_ttl time.Duration
+}
+
+func NewRefreshSessionRepo(db *sqlite.SQLite, refresh_ttl time.Duration) *RefreshSessionRepo {
+	return &RefreshSessionRepo{
+		SQLite:      db,
+		refresh_ttl: refresh_ttl,
+	}
+}
+
+func (r *RefreshSessionRepo) CreateSession(ctx context.Context, digest string, session models.RefreshSession) error {
+	const op = "RefreshSessionRepo.CreateSession"
+	sql := `INSERT INTO refresh_sessions (digest, ` + sessionColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	_, err := r.DB.ExecContext(ctx, sql, digest,
+		session.Id,
+		session.UserId,
+		session.CreatedAt.UTC(),
+		session.LastUsedAt.UTC(),
+		session.IP,
+		session.UserAgent,
+		session.DeviceName,
+		session.Persistent,
+		session.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s - r.DB.ExecContext: %v", op, err)
+	}
+	return nil
+}
+
+func (r *RefreshSessionRepo) GetSession(ctx context.Context, digest string) (models.RefreshSession, error) {
+	const op = "RefreshSessionRepo.GetSession"
+	sql := `SELECT ` + sessionColumns + ` FROM refresh_sessions WHERE digest = ? AND expires_at > ?;`
+	return r.getSession(ctx, op, sql, digest)
+}
+
+// DeleteSession removes the row and returns it in one statement,
+// so a token can be exchanged only once even by concurrent requests
+func (r *RefreshSessionRepo) DeleteSession(ctx context.Context, digest string) (models.RefreshSession, error) {
+	const op = "RefreshSessionRepo.DeleteSession"
+	sql := `DELETE FROM refresh_sessions WHERE digest = ? AND expires_at > ? RETURNING ` + sessionColumns + `;`
+	return r.getSession(ctx, op, sql, digest)
+}
+
+// RotateSession swaps the digest only while the row still holds the old one,
+// a revoked session has no row left to update and stays revoked
+func (r *RefreshSessionRepo) RotateSession(ctx context.Context, oldDigest string, newDigest string, session models.RefreshSession) error {
+	const op = "RefreshSessionRepo.RotateSession"
+	sql := `UPDATE refresh_sessions SET
+			digest = ?,
+			last_used_at = ?,
+			ip = ?,
+			user_agent = ?,
+			device_name = ?,
+			expires_at = ?
+		WHERE session_id = ? AND digest = ? AND expires_at > ?;`
+	res, err := r.DB.ExecContext(ctx, sql, newDigest,
+		session.LastUsedAt.UTC(),
+		session.IP,
+		session.UserAgent,
+		session.DeviceName,
+		session.ExpiresAt.UTC(),
+		session.Id,
+		oldDigest,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s - r.DB.ExecContext: %v", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return repoerrors.ErrNotFound
+	}
+	return nil
+}
+
+func (r *RefreshSessionRepo) MarkSpent(ctx context.Context, digest string, session models.RefreshSession) error {
+	const op = "RefreshSessionRepo.MarkSpent"
+	sql := `INSERT INTO refresh_spent (digest, session_id, user_id, ip, user_agent, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (digest) DO NOTHING;`
+	_, err := r.DB.ExecContext(ctx, sql, digest,
+		session.Id,
+		session.UserId,
+		session.IP,
+		session.UserAgent,
+		time.Now().Add(r.refresh_ttl).UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s - r.DB.ExecContext: %v", op, err)
+	}
+	return nil
+}
+
+func (r *RefreshSessionRepo) GetSpent(ctx context.Context, digest string) (models.RefreshSession, error) {
+	const op = "RefreshSessionRepo.GetSpent"
+	query := `SELECT session_id, user_id, ip, user_agent FROM refresh_spent WHERE digest = ? AND expires_at > ?;`
+	var session models.RefreshSession
+	err := r.DB.QueryRowContext(ctx, query, digest, time.Now().UTC()).Scan(&session.Id, &session.UserId, &session.IP, &session.UserAgent)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.RefreshSession{}, repoerrors.ErrNotFound
+		}
+		return models.RefreshSession{}, fmt.Errorf("%s - r.DB.QueryRowContext: %v", op, err)
+	}
+	return session, nil
+}
+
+func (r *RefreshSessionRepo) ListSessions(ctx context.Context, userId int) ([]models.RefreshSession, error) {
+	const op = "RefreshSessionRepo.ListSessions"
+	query := `SELECT ` + sessionColumns + ` FROM refresh_sessions WHERE user_id = ? AND expires_at > ?;`
+	rows, err := r.DB.QueryContext(ctx, query, userId, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("%s - r.DB.QueryContext: %v", op, err)
+	}
+	defer rows.Close()
+	sessions := []models.RefreshSession{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s - rows.Scan: %v", op, err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s - rows.Err: %v", op, err)
+	}
+	return sessions, nil
+}
+
+func (r *RefreshSessionRepo) RevokeSession(ctx context.Context, userId int, sessionId string) error {
+	const op = "RefreshSessionRepo.RevokeSession"
+	sql := `DELETE FROM refresh_sessions WHERE user_id = ? AND session_id = ?;`
+	res, err := r.DB.ExecContext(ctx, sql, userId, sessionId)
+	if err != nil {
+		return fmt.Errorf("%s - r.DB.ExecContext: %v", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return repoerrors.ErrNotFound
+	}
+	return nil
+}
+
+func (r *RefreshSessionRepo) RevokeAllSessions(ctx context.Context, userId int) error {
+	const op = "RefreshSessionRepo.RevokeAllSessions"
+	sql := `DELETE FROM refresh_sessions WHERE user_id = ?;`
+	if _, err := r.DB.ExecContext(ctx, sql, userId); err != nil {
+		return fmt.Errorf("%s - r.DB.ExecContext: %v", op, err)
+	}
+	return nil
+}
+
+// DeleteExpired removes expired sessions and spent marks, returns the number of removed rows
+func (r *RefreshSessionRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	const op = "RefreshSessionRepo.DeleteExpired"
+	var deleted int64
+	for _, sql := range []string{
+		`DELETE FROM refresh_sessions WHERE expires_at <= ?;`,
+		`DELETE FROM refresh_spent WHERE expires_at <= ?;`,
+	} {
+		res, err := r.DB.ExecContext(ctx, sql, time.Now().UTC())
+		if err != nil {
+			return deleted, fmt.Errorf("%s - r.DB.ExecContext: %v", op, err)
+		}
+		n, _ := res.RowsAffected()
+		deleted += n
+	}
+	return deleted, nil
+}
+
+func (r *RefreshSessionRepo) getSession(ctx context.Context, op string, query string, digest string) (models.RefreshSession, error) {
+	session, err := scanSession(r.DB.QueryRowContext(ctx, query, digest, time.Now().UTC()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.RefreshSession{}, repoerrors.ErrNotFound
+		}
+		return models.RefreshSession{}, fmt.Errorf("%s - r.DB.QueryRowContext: %v", op, err)
+	}
+	return session, nil
+}
+
+func scanSession(row interface{ Scan(...any) error }) (models.RefreshSession, error) {
+	var session models.RefreshSession
+	err := row.Scan(
+		&session.Id,
+		&session.UserId,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.IP,
+		&session.UserAgent,
+		&session.DeviceName,
+		&session.Persistent,
+		&session.ExpiresAt,
+	)
+	return session, err
+}
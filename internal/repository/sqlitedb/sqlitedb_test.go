@@ -0,0 +1,137 @@
+package sqlitedb
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/repository"
+	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
+	"github.com/d1mitrii/authentication-service/internal/repository/repotest"
+	"github.com/d1mitrii/authentication-service/pkg/sqlite"
+)
+
+// openDB creates a migrated database in a temporary directory
+func openDB(t *testing.T) *sqlite.SQLite {
+	t.Helper()
+	db, err := sqlite.New(filepath.Join(t.TempDir(), "auth.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	if err := Migrate(context.Background(), db); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	return db
+}
+
+// openDBWithUsers adds users 1 and 2, sessions and tokens reference them
+func openDBWithUsers(t *testing.T) *sqlite.SQLite {
+	t.Helper()
+	db := openDB(t)
+	users := NewUserRepo(db)
+	for _, email := range []string{"ann@example.com", "bob@example.com"} {
+		if _, err := users.CreateUser(context.Background(), models.User{Email: email, Password: "hash"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestRefreshSessionRepo(t *testing.T) {
+	repotest.RefreshSessions(t, func(t *testing.T) (repository.RefreshSessionRepo, repotest.Advance) {
+		return NewRefreshSessionRepo(openDBWithUsers(t), repotest.RefreshTTL), nil
+	})
+}
+
+func TestTokenDenylist(t *testing.T) {
+	repotest.Denylist(t, func(t *testing.T) (repository.TokenDenylistRepo, repotest.Advance) {
+		return NewTokenDenylist(openDB(t)), nil
+	})
+}
+
+func TestUserRepo(t *testing.T) {
+	repotest.Users(t, func(t *testing.T) repository.UserRepo {
+		return NewUserRepo(openDB(t))
+	})
+}
+
+func TestOneTimeTokenRepo(t *testing.T) {
+	repotest.OneTimeTokens(t, func(t *testing.T) (repository.OneTimeTokenRepo, repotest.Advance) {
+		return NewOneTimeTokenRepo(openDBWithUsers(t)), nil
+	})
+}
+
+func TestMigrateTwice(t *testing.T) {
+	db := openDB(t)
+	if err := Migrate(context.Background(), db); err != nil {
+		t.Errorf("second Migrate() error = %v", err)
+	}
+}
+
+// TestExpiredRows stores rows that expired already, the queries must not see them
+// and DeleteExpired must remove them
+func TestExpiredRows(t *testing.T) {
+	ctx := context.Background()
+	db := openDBWithUsers(t)
+	past := time.Now().Add(-2 * time.Hour)
+
+	// a negative refresh ttl makes spent marks expire at once
+	sessions := NewRefreshSessionRepo(db, -time.Hour)
+	session := repotest.Session("a", 1, past)
+	if err := sessions.CreateSession(ctx, repotest.Digest("a1"), session); err != nil {
+		t.Fatal(err)
+	}
+	if err := sessions.MarkSpent(ctx, repotest.Digest("a0"), session); err != nil {
+		t.Fatal(err)
+	}
+	if err := sessions.CreateSession(ctx, repotest.Digest("b1"), repotest.Session("b", 1, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sessions.GetSession(ctx, repotest.Digest("a1")); !errors.Is(err, repoerrors.ErrNotFound) {
+		t.Errorf("GetSession() of an expired session error = %v", err)
+	}
+	if _, err := sessions.GetSpent(ctx, repotest.Digest("a0")); !errors.Is(err, repoerrors.ErrNotFound) {
+		t.Errorf("GetSpent() of an expired mark error = %v", err)
+	}
+	rotated := repotest.Session("a", 1, time.Now())
+	if err := sessions.RotateSession(ctx, repotest.Digest("a1"), repotest.Digest("a2"), rotated); !errors.Is(err, repoerrors.ErrNotFound) {
+		t.Errorf("RotateSession() of an expired session error = %v", err)
+	}
+	if list, err := sessions.ListSessions(ctx, 1); err != nil || len(list) != 1 || list[0].Id != "b" {
+		t.Errorf("ListSessions() = %+v, %v, want only the live session", list, err)
+	}
+	if deleted, err := sessions.DeleteExpired(ctx); err != nil || deleted != 2 {
+		t.Errorf("DeleteExpired() = %d, %v, want the session and the mark", deleted, err)
+	}
+
+	tokens := NewOneTimeTokenRepo(db)
+	token := models.OneTimeToken{Purpose: models.PurposePasswordReset, UserId: 1, CreatedAt: past, ExpiresAt: past.Add(time.Hour)}
+	if err := tokens.CreateToken(ctx, repotest.Digest("t"), token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokens.GetToken(ctx, models.PurposePasswordReset, repotest.Digest("t")); !errors.Is(err, repoerrors.ErrNotFound) {
+		t.Errorf("GetToken() of an expired token error = %v", err)
+	}
+	if deleted, err := tokens.DeleteExpired(ctx); err != nil || deleted != 1 {
+		t.Errorf("DeleteExpired() of tokens = %d, %v, want 1", deleted, err)
+	}
+}
+
+func TestDeleteUserCascades(t *testing.T) {
+	ctx := context.Background()
+	db := openDBWithUsers(t)
+	sessions := NewRefreshSessionRepo(db, repotest.RefreshTTL)
+	if err := sessions.CreateSession(ctx, repotest.Digest("a1"), repotest.Session("a", 1, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewUserRepo(db).DeleteUser(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sessions.GetSession(ctx, repotest.Digest("a1")); !errors.Is(err, repoerrors.ErrNotFound) {
+		t.Errorf("GetSession() of a deleted user's session error = %v", err)
+	}
+}
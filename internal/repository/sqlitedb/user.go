@@ -0,0 +1,107 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
+	"github.com/d1mitrii/authentication-service/pkg/sqlite"
+	"time"
+)
+
+// UserRepo keeps users in SQLite, roles are stored as a JSON array
+type UserRepo struct {
+	*sqlite.SQLite
+}
+
+func NewUserRepo(db *sqlite.SQLite) *UserRepo {
+	return &UserRepo{db}
+}
+
+func (r *UserRepo) CreateUser(ctx context.Context, user models.User) (int, error) {
+	const op = "UserRepo.CreateUser"
+	sql := `INSERT INTO users(email, password) VALUES (?, ?) RETURNING id;`
+	var id int
+	err := r.DB.QueryRowContext(ctx, sql, user.Email, user.Password).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, repoerrors.ErrAlreadyExist
+		}
+		return 0, fmt.Errorf("%s - r.DB.QueryRowContext: %v", op, err)
+	}
+	return id, nil
+}
+
+func (r *UserRepo) GetUserById(ctx context.Context, id int) (models.User, error) {
+	const op = "UserRepo.GetUserById"
+	sql := `SELECT id, email, password, created_at, roles, email_verified_at FROM users WHERE id = ?;`
+	return r.getUser(ctx, op, sql, id)
+}
+
+func (r *UserRepo) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	const op = "UserRepo.GetUserByEmail"
+	sql := `SELECT id, email, password, created_at, roles, email_verified_at FROM users WHERE email = ?;`
+	return r.getUser(ctx, op, sql, email)
+}
+
+func (r *UserRepo) DeleteUser(ctx context.Context, id int) error {
+	const op = "UserRepo.DeleteUser"
+	sql := `DELETE FROM users WHERE id = ?;`
+	res, err := r.DB.ExecContext(ctx, sql, id)
+	if err != nil {
+		return fmt.Errorf("%s - r.DB.ExecContext: %v", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return repoerrors.ErrNotFound
+	}
+	return nil
+}
+
+func (r *UserRepo) getUser(ctx context.Context, op string, query string, arg any) (models.User, error) {
+	var user models.User
+	var roles string
+	var verifiedAt sql.NullTime
+	err := r.DB.QueryRowContext(ctx, query, arg).Scan(&user.Id, &user.Email, &user.Password, &user.CreatedAt, &roles, &verifiedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, repoerrors.ErrNotFound
+		}
+		return models.User{}, fmt.Errorf("%s - r.DB.QueryRowContext: %v", op, err)
+	}
+	if err := json.Unmarshal([]byte(roles), &user.Roles); err != nil {
+		return models.User{}, fmt.Errorf("%s - json.Unmarshal: %v", op, err)
+	}
+	if verifiedAt.Valid {
+		user.EmailVerifiedAt = &verifiedAt.Time
+	}
+	return user, nil
+}
+
+func (r *UserRepo) UpdatePassword(ctx context.Context, userId int, hash string) error {
+	const op = "UserRepo.UpdatePassword"
+	sql := `UPDATE users SET password = ? WHERE id = ?;`
+	res, err := r.DB.ExecContext(ctx, sql, hash, userId)
+	if err != nil {
+		return fmt.Errorf("%s - r.DB.ExecContext: %v", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return repoerrors.ErrNotFound
+	}
+	return nil
+}
+
+func (r *UserRepo) MarkEmailVerified(ctx context.Context, userId int, at time.Time) error {
+	const op = "UserRepo.MarkEmailVerified"
+	sql := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, ?) WHERE id = ?;`
+	res, err := r.DB.ExecContext(ctx, sql, at.UTC(), userId)
+	if err != nil {
+		return fmt.Errorf("%s - r.DB.ExecContext: %v", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return repoerrors.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package breach
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// A filter file is the magic, k, the min count and m as big endian integers followed by m bits
+const (
+	bloomMagic      = "PWNDBLM1"
+	bloomHeaderSize = len(bloomMagic) + 4 + 4 + 8
+)
+
+// Bloom checks passwords against a Bloom filter of breached SHA-1 hashes.
+// It may report a password that was never breached with the false positive rate
+// the filter was built for, it never misses one. Lookups read the bits from disk.
+type Bloom struct {
+	file     *os.File
+	k        uint32
+	m        uint64
+	minCount int
+}
+
+// newBloom reads the header of a filter file positioned after the magic
+func newBloom(file *os.File) (*Bloom, error) {
+	header := make([]byte, bloomHeaderSize-len(bloomMagic))
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, fmt.Errorf("bloom header: %w", err)
+	}
+	b := &Bloom{
+		file:     file,
+		k:        binary.BigEndian.Uint32(header[0:4]),
+		minCount: int(binary.BigEndian.Uint32(header[4:8])),
+		m:        binary.BigEndian.Uint64(header[8:16]),
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if b.k == 0 || b.m == 0 || info.Size() < int64(bloomHeaderSize)+int64((b.m+7)/8) {
+		return nil, fmt.Errorf("corrupted bloom filter")
+	}
+	return b, nil
+}
+
+func (b *Bloom) Breached(password string) (bool, error) {
+	const op = "Bloom.Breached"
+	sum := sha1.Sum([]byte(password))
+	bit := make([]byte, 1)
+	for _, i := range bloomIndexes(sum, b.k, b.m) {
+		if _, err := b.file.ReadAt(bit, int64(bloomHeaderSize)+int64(i/8)); err != nil {
+			return false, fmt.Errorf("%s - file.ReadAt: %w", op, err)
+		}
+		if bit[0]&(1<<(i%8)) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (b *Bloom) Close() error {
+	return b.file.Close()
+}
+
+// BloomBuilder collects hashes into a filter held in memory,
+// it takes -log2(falsePositive)/ln2 bits per hash: 1.8 bytes at 0.001
+type BloomBuilder struct {
+	k        uint32
+	m        uint64
+	minCount int
+	bits     []byte
+}
+
+// NewBloomBuilder sizes the filter for n hashes at the false positive rate,
+// minCount is recorded so that the service can check its threshold matches
+func NewBloomBuilder(n uint64, falsePositive float64, minCount int) *BloomBuilder {
+	n = max(n, 1)
+	m := uint64(math.Ceil(-float64(n) * math.Log(falsePositive) / (math.Ln2 * math.Ln2)))
+	k := uint32(max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &BloomBuilder{
+		k:        k,
+		m:        m,
+		minCount: minCount,
+		bits:     make([]byte, (m+7)/8),
+	}
+}
+
+func (b *BloomBuilder) Add(sum [sha1.Size]byte) {
+	for _, i := range bloomIndexes(sum, b.k, b.m) {
+		b.bits[i/8] |= 1 << (i % 8)
+	}
+}
+
+// Size is the size of the filter file in bytes
+func (b *BloomBuilder) Size() int64 {
+	return int64(bloomHeaderSize + len(b.bits))
+}
+
+func (b *BloomBuilder) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, 0, bloomHeaderSize)
+	header = append(header, bloomMagic...)
+	header = binary.BigEndian.AppendUint32(header, b.k)
+	header = binary.BigEndian.AppendUint32(header, uint32(b.minCount))
+	header = binary.BigEndian.AppendUint64(header, b.m)
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+	written, err := w.Write(b.bits)
+	return int64(n + written), err
+}
+
+// bloomIndexes derives k bit positions from the hash by double hashing,
+// SHA-1 output is already uniform so no further hashing is needed
+func bloomIndexes(sum [sha1.Size]byte, k uint32, m uint64) []uint64 {
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+	indexes := make([]uint64, k)
+	for i := range indexes {
+		indexes[i] = (h1 + uint64(i)*h2) % m
+	}
+	return indexes
+}
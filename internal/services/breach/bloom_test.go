@@ -0,0 +1,130 @@
+package breach
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeBloom builds a filter of "password-<i>" for i below n and writes it to a file
+func writeBloom(t *testing.T, n int, falsePositive float64, minCount int) string {
+	t.Helper()
+	builder := NewBloomBuilder(uint64(n), falsePositive, minCount)
+	for i := 0; i < n; i++ {
+		builder.Add(sha1.Sum([]byte(fmt.Sprintf("password-%d", i))))
+	}
+	path := filepath.Join(t.TempDir(), "breached.bloom")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := builder.WriteTo(file)
+	if err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if written != builder.Size() {
+		t.Errorf("WriteTo() wrote %d bytes, Size() = %d", written, builder.Size())
+	}
+	return path
+}
+
+func openBloom(t *testing.T, path string, minCount int) *Bloom {
+	t.Helper()
+	checker, err := Open(path, minCount)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { checker.Close() })
+	bloom, ok := checker.(*Bloom)
+	if !ok {
+		t.Fatalf("Open() = %T, want *Bloom", checker)
+	}
+	return bloom
+}
+
+func TestBloomRoundTrip(t *testing.T) {
+	const n = 2000
+	path := writeBloom(t, n, 0.01, 3)
+	bloom := openBloom(t, path, 3)
+
+	builder := NewBloomBuilder(n, 0.01, 3)
+	if bloom.k != builder.k || bloom.m != builder.m || bloom.minCount != 3 {
+		t.Errorf("header k=%d m=%d min count=%d, want k=%d m=%d min count=3", bloom.k, bloom.m, bloom.minCount, builder.k, builder.m)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != builder.Size() {
+		t.Errorf("file size %d, want %d", info.Size(), builder.Size())
+	}
+	for i := 0; i < n; i++ {
+		password := fmt.Sprintf("password-%d", i)
+		if got, err := bloom.Breached(password); err != nil || !got {
+			t.Errorf("Breached(%q) = %v, %v, want true", password, got, err)
+		}
+	}
+}
+
+func TestBloomFalsePositiveRate(t *testing.T) {
+	const (
+		n             = 5000
+		falsePositive = 0.01
+		probes        = 50000
+	)
+	bloom := openBloom(t, writeBloom(t, n, falsePositive, 1), 1)
+	positives := 0
+	for i := 0; i < probes; i++ {
+		got, err := bloom.Breached(fmt.Sprintf("never-breached-%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got {
+			positives++
+		}
+	}
+	// the probes are fixed, so the rate is the same on every run
+	if rate := float64(positives) / probes; rate > 2*falsePositive {
+		t.Errorf("false positive rate %.4f, built for %.4f", rate, falsePositive)
+	}
+}
+
+func TestBloomMinCountMismatch(t *testing.T) {
+	path := writeBloom(t, 10, 0.01, 5)
+	if checker, err := Open(path, 1); err == nil {
+		checker.Close()
+		t.Error("Open() with another min count error = nil")
+	}
+}
+
+func TestBloomCorrupted(t *testing.T) {
+	data, err := os.ReadFile(writeBloom(t, 1000, 0.01, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "truncated bits", data: data[:len(data)-1]},
+		{name: "truncated header", data: data[:bloomHeaderSize-1]},
+		{name: "zero k", data: append(append([]byte(bloomMagic), 0, 0, 0, 0), data[len(bloomMagic)+4:]...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "breached.bloom")
+			if err := os.WriteFile(path, tt.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if checker, err := Open(path, 1); err == nil {
+				checker.Close()
+				t.Error("Open() of a corrupted filter error = nil")
+			}
+		})
+	}
+}
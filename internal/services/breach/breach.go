@@ -0,0 +1,85 @@
+package breach
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Checker reports whether a password appears in a breach corpus
+type Checker interface {
+	Breached(password string) (bool, error)
+	Close() error
+}
+
+// Open picks the format from path: a directory of range files, a Bloom filter built
+// by cmd/breach-filter, or the Pwned Passwords dump ordered by hash.
+// Passwords seen fewer than minCount times are accepted. A Bloom filter stores no counts,
+// its threshold is set when it is built and has to match minCount.
+func Open(path string, minCount int) (Checker, error) {
+	const op = "breach.Open"
+	if minCount < 1 {
+		minCount = 1
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s - os.Stat: %w", op, err)
+	}
+	if info.IsDir() {
+		return NewRangeDir(path, minCount), nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s - os.Open: %w", op, err)
+	}
+	magic := make([]byte, len(bloomMagic))
+	if _, err := io.ReadFull(file, magic); err == nil && string(magic) == bloomMagic {
+		bloom, err := newBloom(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if bloom.minCount != minCount {
+			file.Close()
+			return nil, fmt.Errorf("%s: filter was built with min count %d, configured %d", op, bloom.minCount, minCount)
+		}
+		return bloom, nil
+	}
+	return &SortedFile{file: file, size: info.Size(), minCount: minCount}, nil
+}
+
+// ParseLine parses a "SHA1:COUNT" line of the Pwned Passwords dump
+func ParseLine(line []byte) ([sha1.Size]byte, int, error) {
+	var sum [sha1.Size]byte
+	hash, count, ok := bytes.Cut(bytes.TrimSpace(line), []byte(":"))
+	if !ok || len(hash) != hex.EncodedLen(sha1.Size) {
+		return sum, 0, errMalformed
+	}
+	if _, err := hex.Decode(sum[:], hash); err != nil {
+		return sum, 0, errMalformed
+	}
+	n, err := parseCount(count)
+	return sum, n, err
+}
+
+var errMalformed = errors.New("malformed line, expected SHA1:COUNT")
+
+func parseCount(count []byte) (int, error) {
+	n, err := strconv.Atoi(string(bytes.TrimSpace(count)))
+	if err != nil {
+		return 0, errMalformed
+	}
+	return n, nil
+}
+
+// hashHex is the upper-case hex SHA-1 of the password as used by the Pwned Passwords corpus
+func hashHex(password string) []byte {
+	sum := sha1.Sum([]byte(password))
+	return bytes.ToUpper([]byte(hex.EncodeToString(sum[:])))
+}
@@ -0,0 +1,61 @@
+package breach
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const prefixLength = 5
+
+// RangeDir looks passwords up in range files as served by the Pwned Passwords API:
+// one file per 5 character hash prefix named "<PREFIX>.txt" with "SUFFIX:COUNT" lines
+type RangeDir struct {
+	dir      string
+	minCount int
+}
+
+func NewRangeDir(dir string, minCount int) *RangeDir {
+	return &RangeDir{
+		dir:      dir,
+		minCount: minCount,
+	}
+}
+
+func (d *RangeDir) Breached(password string) (bool, error) {
+	const op = "RangeDir.Breached"
+	hash := hashHex(password)
+	file, err := os.Open(filepath.Join(d.dir, string(hash[:prefixLength])+".txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("%s - os.Open: %w", op, err)
+	}
+	defer file.Close()
+
+	suffix := hash[prefixLength:]
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, count, ok := bytes.Cut(scanner.Bytes(), []byte(":"))
+		if !ok || !bytes.EqualFold(line, suffix) {
+			continue
+		}
+		n, err := parseCount(count)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+		return n >= d.minCount, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("%s - scanner.Scan: %w", op, err)
+	}
+	return false, nil
+}
+
+func (d *RangeDir) Close() error {
+	return nil
+}
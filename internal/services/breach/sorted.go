@@ -0,0 +1,112 @@
+package breach
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// lines of the dump are 40 hex characters, a colon and the count
+const maxLineLength = 64
+
+// SortedFile looks passwords up in the single file Pwned Passwords dump ordered by hash.
+// The file is binary searched on disk, nothing is loaded into memory.
+type SortedFile struct {
+	file     *os.File
+	size     int64
+	minCount int
+}
+
+func (f *SortedFile) Breached(password string) (bool, error) {
+	const op = "SortedFile.Breached"
+	target := hashHex(password)
+
+	// find the first line whose hash is not less than target
+	lo, hi := int64(0), f.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := f.lineAt(mid)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+		if line == nil {
+			hi = mid
+			continue
+		}
+		cmp, err := compareHash(line, target)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+		if cmp < 0 {
+			lo = start + int64(len(line)) + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	_, line, err := f.lineAt(lo)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if line == nil {
+		return false, nil
+	}
+	if cmp, err := compareHash(line, target); err != nil || cmp != 0 {
+		return false, err
+	}
+	_, count, err := ParseLine(line)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return count >= f.minCount, nil
+}
+
+func (f *SortedFile) Close() error {
+	return f.file.Close()
+}
+
+// lineAt returns the first line starting at or after offset without its newline,
+// nil past the end of the file
+func (f *SortedFile) lineAt(offset int64) (int64, []byte, error) {
+	buf := make([]byte, maxLineLength)
+	start := offset
+	if offset > 0 {
+		// offset-1 is either the newline ending the previous line or inside it
+		n, err := f.file.ReadAt(buf, offset-1)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, nil, err
+		}
+		i := bytes.IndexByte(buf[:n], '\n')
+		if i < 0 {
+			if n < len(buf) {
+				return 0, nil, nil
+			}
+			return 0, nil, errMalformed
+		}
+		start = offset + int64(i)
+	}
+	if start >= f.size {
+		return start, nil, nil
+	}
+
+	n, err := f.file.ReadAt(buf, start)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, nil, err
+	}
+	line := buf[:n]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	} else if start+int64(n) < f.size {
+		return 0, nil, errMalformed
+	}
+	return start, line, nil
+}
+
+func compareHash(line, target []byte) (int, error) {
+	if len(line) < len(target) {
+		return 0, errMalformed
+	}
+	return bytes.Compare(bytes.ToUpper(line[:len(target)]), target), nil
+}
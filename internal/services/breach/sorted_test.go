@@ -0,0 +1,117 @@
+package breach
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeDump writes a dump ordered by hash where "password-<i>" was seen i+1 times
+func writeDump(t *testing.T, n int, newline string, lower, finalNewline bool) string {
+	t.Helper()
+	lines := make([]string, n)
+	for i := range lines {
+		hash := hashHex(fmt.Sprintf("password-%d", i))
+		if lower {
+			hash = bytes.ToLower(hash)
+		}
+		lines[i] = fmt.Sprintf("%s:%d", hash, i+1)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		return bytes.Compare(bytes.ToUpper([]byte(lines[i][:40])), bytes.ToUpper([]byte(lines[j][:40]))) < 0
+	})
+	data := strings.Join(lines, newline)
+	if finalNewline {
+		data += newline
+	}
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSortedFile(t *testing.T) {
+	const n = 500
+	dumps := []struct {
+		name      string
+		newline   string
+		lower     bool
+		noNewline bool
+	}{
+		{name: "LF", newline: "\n"},
+		{name: "CRLF", newline: "\r\n"},
+		{name: "lower-case hashes", newline: "\n", lower: true},
+		{name: "no final newline", newline: "\r\n", noNewline: true},
+	}
+	tests := []struct {
+		password string
+		minCount int
+		want     bool
+	}{
+		{password: "password-0", minCount: 1, want: true},
+		{password: "password-0", minCount: 2, want: false},
+		{password: "password-250", minCount: 251, want: true},
+		{password: "password-250", minCount: 252, want: false},
+		{password: "password-499", minCount: 1, want: true},
+		{password: "password-500", minCount: 1, want: false},
+		{password: "", minCount: 1, want: false},
+		{password: "correct horse battery staple", minCount: 1, want: false},
+	}
+	for _, dump := range dumps {
+		t.Run(dump.name, func(t *testing.T) {
+			path := writeDump(t, n, dump.newline, dump.lower, !dump.noNewline)
+			for _, tt := range tests {
+				checker, err := Open(path, tt.minCount)
+				if err != nil {
+					t.Fatalf("Open() error = %v", err)
+				}
+				if _, ok := checker.(*SortedFile); !ok {
+					t.Fatalf("Open() = %T, want *SortedFile", checker)
+				}
+				got, err := checker.Breached(tt.password)
+				checker.Close()
+				if err != nil {
+					t.Fatalf("Breached(%q) error = %v", tt.password, err)
+				}
+				if got != tt.want {
+					t.Errorf("Breached(%q) with min count %d = %v, want %v", tt.password, tt.minCount, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSortedFileFindsEveryEntry(t *testing.T) {
+	const n = 300
+	checker, err := Open(writeDump(t, n, "\r\n", false, true), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer checker.Close()
+	for i := 0; i < n; i++ {
+		password := fmt.Sprintf("password-%d", i)
+		if got, err := checker.Breached(password); err != nil || !got {
+			t.Errorf("Breached(%q) = %v, %v, want true", password, got, err)
+		}
+	}
+}
+
+func TestSortedFileMalformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte("not a hash\nnot a hash either\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	checker, err := Open(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer checker.Close()
+	if _, err := checker.Breached("password"); err == nil {
+		t.Error("Breached() on a malformed dump error = nil")
+	}
+}
@@ -0,0 +1,33 @@
+package clients
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+)
+
+// Registry holds credentials of the clients allowed to call
+// protected endpoints such as token introspection
+type Registry struct {
+	secrets map[string][32]byte
+}
+
+// New builds a registry from client id to client secret pairs
+func New(clients map[string]string) *Registry {
+	r := &Registry{
+		secrets: make(map[string][32]byte, len(clients)),
+	}
+	for id, secret := range clients {
+		r.secrets[id] = sha256.Sum256([]byte(secret))
+	}
+	return r
+}
+
+// Authenticate checks client credentials in constant time
+func (r *Registry) Authenticate(id, secret string) bool {
+	expected, ok := r.secrets[id]
+	if !ok {
+		return false
+	}
+	actual := sha256.Sum256([]byte(secret))
+	return subtle.ConstantTimeCompare(expected[:], actual[:]) == 1
+}
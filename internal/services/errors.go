@@ -1,16 +1,33 @@
 package services
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrUserAlreadyExist  = errors.New("user already exist")
 	ErrUserNotFound      = errors.New("user not found")
 	ErrIncorrectPassword = errors.New("incorrect user password")
+	ErrEmailNotVerified  = errors.New("email is not verified")
+
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrInvalidResetToken        = errors.New("invalid or expired password reset token")
 
 	ErrSessionCreateFail = errors.New("failed to create refresh session")
 	ErrSessionNotFound   = errors.New("refresh session not found")
+	ErrSessionLimit      = errors.New("too many active sessions")
+	ErrSessionExpired    = errors.New("refresh session expired")
 
 	ErrCannotSignToken = errors.New("cannot sign token")
 
+	// Every reason to reject an access token wraps ErrInvalidToken
+	ErrInvalidToken       = errors.New("invalid access token")
+	ErrTokenMalformed     = fmt.Errorf("%w: malformed", ErrInvalidToken)
+	ErrTokenExpired       = fmt.Errorf("%w: expired", ErrInvalidToken)
+	ErrTokenBadSignature  = fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	ErrTokenInvalidClaims = fmt.Errorf("%w: invalid claims", ErrInvalidToken)
+	ErrTokenRevoked       = fmt.Errorf("%w: revoked", ErrInvalidToken)
+
 	ErrHashing = errors.New("failed to create a password hash")
 )
@@ -2,89 +2,217 @@ package jwt
 
 import (
 	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"github.com/d1mitrii/authentication-service/internal/models"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+const RefreshTokenPrefix = "rt_"
+
+func init() {
+	// iat and the other times carry microseconds, so a token issued right after a revocation
+	// can be told apart from the ones it revoked within the same second
+	jwt.TimePrecision = time.Microsecond
+}
+
+// Errors returned by Parse can be matched against these with errors.Is
+var (
+	ErrTokenMalformed        = jwt.ErrTokenMalformed
+	ErrTokenExpired          = jwt.ErrTokenExpired
+	ErrTokenSignatureInvalid = jwt.ErrTokenSignatureInvalid
+	ErrTokenUnverifiable     = jwt.ErrTokenUnverifiable
+)
+
 type TokenClaims struct {
-	Id int `json:"id"`
+	Id    int      `json:"id"`
+	Email string   `json:"email"`
+	Roles []string `json:"roles,omitempty"`
+	// SessionId is the refresh session the token was issued for
+	SessionId string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
 type JWT struct {
-	secret_key  []byte
+	keys        *Keyring
+	issuer      string
+	audience    []string
 	access_ttl  time.Duration
 	refresh_ttl time.Duration
+	now         func() time.Time
+}
+
+type Option func(*JWT)
+
+// Clock sets the source of the current time used to issue and check tokens
+func Clock(now func() time.Time) Option {
+	return func(r *JWT) {
+		r.now = now
+	}
 }
 
 func New(
-	secret string,
+	keys *Keyring,
+	issuer string,
+	audience []string,
 	access_ttl time.Duration,
 	refresh_ttl time.Duration,
+	opts ...Option,
 ) *JWT {
-	return &JWT{
-		secret_key:  []byte(secret),
+	r := &JWT{
+		keys:        keys,
+		issuer:      issuer,
+		audience:    audience,
 		access_ttl:  access_ttl,
 		refresh_ttl: refresh_ttl,
+		now:         time.Now,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *JWT) AccessTTL() time.Duration {
+	return r.access_ttl
 }
 
 func (r *JWT) RefreshTTL() time.Duration {
 	return r.refresh_ttl
 }
 
-func (r *JWT) NewAccessToken(user models.User) (string, error) {
+// Algorithm returns the algorithm new tokens are signed with
+func (r *JWT) Algorithm() string {
+	return r.keys.Active().Alg()
+}
+
+func (r *JWT) NewAccessToken(user models.User, sessionId string) (string, error) {
+	jti, err := tokenId()
+	if err != nil {
+		return "", err
+	}
+	now := r.now()
 	claims := &TokenClaims{
 		user.Id,
+		user.Email,
+		user.Roles,
+		sessionId,
 		jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(r.access_ttl)),
+			Issuer:    r.issuer,
+			Subject:   strconv.Itoa(user.Id),
+			Audience:  r.audience,
+			ExpiresAt: jwt.NewNumericDate(now.Add(r.access_ttl)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        jti,
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	key := r.keys.Active()
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
 
-	tokenStr, err := token.SignedString(r.secret_key)
+	tokenStr, err := token.SignedString(key.signKey)
 	if err != nil {
 		return "", err
 	}
 	return tokenStr, nil
 }
 
+// NewRefreshToken generates an opaque high-entropy refresh token.
+// The prefix makes leaked tokens easy to recognize by secret scanners.
 func (r *JWT) NewRefreshToken() (string, error) {
-	// Getting random bytes
 	b := make([]byte, 32)
-	_, err := rand.Read(b)
-	if err != nil {
-		return "", nil
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(r.refresh_ttl)),
-	})
-	tokenStr, err := token.SignedString(b)
-	if err != nil {
+	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
-	return tokenStr, nil
+	return RefreshTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
 }
 
-func (r *JWT) Parse(accessToken string) (int, error) {
+// Parse verifies the signature, lifetime, issuer and audience of the access token
+func (r *JWT) Parse(accessToken string) (models.Claims, error) {
 	token, err := jwt.ParseWithClaims(accessToken, &TokenClaims{}, func(token *jwt.Token) (i interface{}, err error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		key, err := r.verificationKey(token)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 
-		return r.secret_key, nil
-	})
+		return key.verifyKey, nil
+	},
+		jwt.WithIssuer(r.issuer),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(r.now),
+	)
 
 	if err != nil {
-		return 0, err
+		return models.Claims{}, err
 	}
 
 	claims, ok := token.Claims.(*TokenClaims)
 	if !ok {
-		return 0, fmt.Errorf("failed to map token")
+		return models.Claims{}, fmt.Errorf("failed to map token")
+	}
+	if len(r.audience) > 0 && !slices.ContainsFunc(claims.Audience, func(aud string) bool {
+		return slices.Contains(r.audience, aud)
+	}) {
+		return models.Claims{}, fmt.Errorf("%w: %v", jwt.ErrTokenInvalidAudience, claims.Audience)
+	}
+	return toModel(claims), nil
+}
+
+func toModel(claims *TokenClaims) models.Claims {
+	result := models.Claims{
+		UserId:    claims.Id,
+		Email:     claims.Email,
+		Roles:     claims.Roles,
+		SessionId: claims.SessionId,
+		ID:        claims.ID,
+		Issuer:    claims.Issuer,
+		Subject:   claims.Subject,
+		Audience:  claims.Audience,
+	}
+	if claims.IssuedAt != nil {
+		result.IssuedAt = claims.IssuedAt.Time
+	}
+	if claims.NotBefore != nil {
+		result.NotBefore = claims.NotBefore.Time
+	}
+	if claims.ExpiresAt != nil {
+		result.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return result
+}
+
+// tokenId generates a unique jti
+func tokenId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// verificationKey picks the key by the kid header,
+// tokens issued before key ids were introduced are checked with the active key
+func (r *JWT) verificationKey(token *jwt.Token) (*Key, error) {
+	kid, ok := token.Header["kid"]
+	if !ok {
+		return r.keys.Active(), nil
+	}
+	id, ok := kid.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid kid header: %v", kid)
+	}
+	key, ok := r.keys.Get(id)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %s", id)
 	}
-	return claims.Id, nil
+	return key, nil
 }
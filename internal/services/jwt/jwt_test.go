@@ -0,0 +1,113 @@
+package jwt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+)
+
+var testUser = models.User{Id: 42, Email: "ann@example.com", Roles: []string{"admin"}}
+
+func newTestJWT(t *testing.T, issuer string, audience []string, now func() time.Time) *JWT {
+	t.Helper()
+	keyring, err := NewKeyring(StaticKey("HS256", "secret", ""), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(keyring, issuer, audience, time.Hour, 72*time.Hour, Clock(now))
+}
+
+func TestNewRefreshToken(t *testing.T) {
+	tokens := &JWT{}
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		token, err := tokens.NewRefreshToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(token, RefreshTokenPrefix) || len(token) != len(RefreshTokenPrefix)+43 {
+			t.Fatalf("NewRefreshToken() = %q, want %q and 32 random bytes", token, RefreshTokenPrefix)
+		}
+		if seen[token] {
+			t.Fatalf("NewRefreshToken() returned %q twice", token)
+		}
+		seen[token] = true
+	}
+}
+
+func TestAccessToken(t *testing.T) {
+	issued := time.Date(2026, 1, 1, 12, 0, 0, 123456789, time.UTC)
+	tokens := newTestJWT(t, "https://auth.example.com", []string{"api"}, func() time.Time { return issued })
+	token, err := tokens.NewAccessToken(testUser, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := tokens.Parse(token)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if claims.UserId != 42 || claims.Subject != "42" || claims.Email != testUser.Email || claims.SessionId != "session" || claims.ID == "" {
+		t.Errorf("claims = %+v", claims)
+	}
+	if claims.Issuer != "https://auth.example.com" || len(claims.Audience) != 1 || claims.Audience[0] != "api" {
+		t.Errorf("claims = %+v, want the issuer and the audience", claims)
+	}
+	if len(claims.Roles) != 1 || claims.Roles[0] != "admin" {
+		t.Errorf("claims = %+v, want the admin role", claims)
+	}
+	// iat keeps microseconds, revocations within the same second tell tokens apart
+	if want := issued.Truncate(time.Microsecond); !claims.IssuedAt.Equal(want) || !claims.NotBefore.Equal(want) {
+		t.Errorf("iat = %v, nbf = %v, want %v", claims.IssuedAt, claims.NotBefore, want)
+	}
+	if want := issued.Truncate(time.Microsecond).Add(time.Hour); !claims.ExpiresAt.Equal(want) {
+		t.Errorf("exp = %v, want %v", claims.ExpiresAt, want)
+	}
+
+	other, err := tokens.NewAccessToken(testUser, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherClaims, err := tokens.Parse(other); err != nil || otherClaims.ID == claims.ID {
+		t.Errorf("two tokens share the jti %q", claims.ID)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	issued := time.Date(2026, 1, 1, 12, 0, 0, 123456789, time.UTC)
+	now := issued
+	tokens := newTestJWT(t, "https://auth.example.com", []string{"api"}, func() time.Time { return now })
+	token, err := tokens.NewAccessToken(testUser, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		parser *JWT
+		token  string
+		now    time.Time
+		want   error
+	}{
+		{name: "expired", parser: tokens, token: token, now: issued.Add(time.Hour), want: ErrTokenExpired},
+		{name: "issued in the future", parser: tokens, token: token, now: issued.Add(-time.Minute)},
+		{name: "other issuer", parser: newTestJWT(t, "https://other.example.com", []string{"api"}, func() time.Time { return now }), token: token, now: issued},
+		{name: "other audience", parser: newTestJWT(t, "https://auth.example.com", []string{"billing"}, func() time.Time { return now }), token: token, now: issued},
+		{name: "tampered", parser: tokens, token: token[:len(token)-2] + "xx", now: issued, want: ErrTokenSignatureInvalid},
+		{name: "malformed", parser: tokens, token: "not a token", now: issued, want: ErrTokenMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = tt.now
+			_, err := tt.parser.Parse(tt.token)
+			if err == nil {
+				t.Fatal("Parse() error = nil")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Parse() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
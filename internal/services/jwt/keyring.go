@@ -0,0 +1,110 @@
+package jwt
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Keyring holds the active signing key and the retired keys
+// that are still accepted for verification.
+type Keyring struct {
+	mu        sync.RWMutex
+	load      KeyLoader
+	retention time.Duration
+	active    *Key
+	keys      map[string]*Key
+	retired   map[string]time.Time
+}
+
+// NewKeyring loads the initial set of keys.
+// Retention is how long a key removed from the source keeps verifying tokens,
+// normally the access token TTL.
+func NewKeyring(load KeyLoader, retention time.Duration) (*Keyring, error) {
+	k := &Keyring{
+		load:      load,
+		retention: retention,
+		keys:      make(map[string]*Key),
+		retired:   make(map[string]time.Time),
+	}
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reload loads the keys from the source again and switches signing to the newest one.
+// Keys that disappeared from the source stay accepted for verification
+// for the retention period, so outstanding tokens remain valid until they expire.
+func (k *Keyring) Reload() error {
+	keys, err := k.load()
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := time.Now()
+	next := make(map[string]*Key, len(keys))
+	for _, key := range keys {
+		next[key.id] = key
+	}
+	retired := make(map[string]time.Time)
+	for id, key := range k.keys {
+		if _, ok := next[id]; ok {
+			continue
+		}
+		retiredAt, ok := k.retired[id]
+		if !ok {
+			retiredAt = now
+		}
+		if now.Sub(retiredAt) < k.retention {
+			next[id] = key
+			retired[id] = retiredAt
+		}
+	}
+
+	k.active = keys[len(keys)-1]
+	k.keys = next
+	k.retired = retired
+	return nil
+}
+
+// Active returns the key used to sign new tokens
+func (k *Keyring) Active() *Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// Get returns the key with the given id if it is still accepted for verification
+func (k *Keyring) Get(id string) (*Key, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, false
+	}
+	if retiredAt, ok := k.retired[id]; ok && time.Since(retiredAt) >= k.retention {
+		return nil, false
+	}
+	return key, true
+}
+
+// Keys returns every key accepted for verification ordered by id
+func (k *Keyring) Keys() []*Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	keys := make([]*Key, 0, len(k.keys))
+	for id, key := range k.keys {
+		if retiredAt, ok := k.retired[id]; ok && time.Since(retiredAt) >= k.retention {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].id < keys[j].id
+	})
+	return keys
+}
@@ -0,0 +1,153 @@
+package jwt
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+)
+
+func writeKey(t *testing.T, dir, id string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, id+".key"), []byte("secret of "+id), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func keyIds(k *Keyring) []string {
+	var ids []string
+	for _, key := range k.Keys() {
+		ids = append(ids, key.ID())
+	}
+	return ids
+}
+
+func TestKeyringReload(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention time.Duration
+		// age of the retirement of "a" before the second reload
+		retiredFor time.Duration
+		want       []string
+	}{
+		{name: "retired key kept within retention", retention: time.Hour, retiredFor: 59 * time.Minute, want: []string{"a", "b"}},
+		{name: "retired key dropped after retention", retention: time.Hour, retiredFor: time.Hour, want: []string{"b"}},
+		{name: "no retention", retention: 0, want: []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeKey(t, dir, "a")
+			keyring, err := NewKeyring(KeyDir("HS256", dir), tt.retention)
+			if err != nil {
+				t.Fatalf("NewKeyring() error = %v", err)
+			}
+			if id := keyring.Active().ID(); id != "a" {
+				t.Fatalf("active key = %q, want a", id)
+			}
+
+			// rotate: b becomes the signing key and a leaves the source
+			writeKey(t, dir, "b")
+			if err := os.Remove(filepath.Join(dir, "a.key")); err != nil {
+				t.Fatal(err)
+			}
+			if err := keyring.Reload(); err != nil {
+				t.Fatalf("Reload() error = %v", err)
+			}
+			if id := keyring.Active().ID(); id != "b" {
+				t.Fatalf("active key after rotation = %q, want b", id)
+			}
+
+			if tt.retention > 0 {
+				// the first reload keeps a for the whole retention
+				if _, ok := keyring.Get("a"); !ok {
+					t.Fatal("retired key a not accepted right after the rotation")
+				}
+				keyring.retired["a"] = time.Now().Add(-tt.retiredFor)
+				if err := keyring.Reload(); err != nil {
+					t.Fatalf("Reload() error = %v", err)
+				}
+			}
+			if got := keyIds(keyring); !slices.Equal(got, tt.want) {
+				t.Errorf("keys = %v, want %v", got, tt.want)
+			}
+			_, ok := keyring.Get("a")
+			if want := slices.Contains(tt.want, "a"); ok != want {
+				t.Errorf("Get(a) ok = %v, want %v", ok, want)
+			}
+		})
+	}
+}
+
+func TestKeyringRetiredKeyExpiresWithoutReload(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "a")
+	keyring, err := NewKeyring(KeyDir("HS256", dir), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, "b")
+	os.Remove(filepath.Join(dir, "a.key"))
+	if err := keyring.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	keyring.retired["a"] = time.Now().Add(-time.Hour)
+	if _, ok := keyring.Get("a"); ok {
+		t.Error("Get(a) accepted a key past its retention")
+	}
+	if got := keyIds(keyring); !slices.Equal(got, []string{"b"}) {
+		t.Errorf("keys = %v, want [b]", got)
+	}
+}
+
+func TestKeyringRestoredKeyIsNotRetired(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "a")
+	keyring, err := NewKeyring(KeyDir("HS256", dir), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, "b")
+	os.Remove(filepath.Join(dir, "a.key"))
+	if err := keyring.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, "a")
+	if err := keyring.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := keyring.retired["a"]; ok {
+		t.Error("key a is still retired after it returned to the source")
+	}
+}
+
+func TestTokensOfRetiredKeyVerify(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "a")
+	keyring, err := NewKeyring(KeyDir("HS256", dir), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := New(keyring, "https://auth.example.com", []string{"api"}, time.Hour, time.Hour)
+	token, err := tokens.NewAccessToken(models.User{Id: 1, Email: "ann@example.com"}, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeKey(t, dir, "b")
+	os.Remove(filepath.Join(dir, "a.key"))
+	if err := keyring.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokens.Parse(token); err != nil {
+		t.Errorf("Parse() of a token signed with the retired key error = %v", err)
+	}
+
+	keyring.retired["a"] = time.Now().Add(-time.Hour)
+	if _, err := tokens.Parse(token); err == nil {
+		t.Error("Parse() accepted a token signed with a key past its retention")
+	}
+}
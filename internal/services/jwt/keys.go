@@ -0,0 +1,198 @@
+package jwt
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const minRSAKeyBits = 2048
+
+// Key holds the material used to sign tokens and the material used to verify them.
+// For HMAC both are the shared secret, for asymmetric algorithms
+// verification is done with the public key only.
+type Key struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+}
+
+// KeyLoader returns the current set of keys, the last one is used for signing
+type KeyLoader func() ([]*Key, error)
+
+// StaticKey loads a single key from configuration.
+// HMAC algorithms (HS256, HS384, HS512) use secret,
+// RSA (RS*, PS*), ECDSA (ES*) and Ed25519 (EdDSA) load a PEM private key from privateKeyPath.
+// The key id is derived from the key itself, so replacing the key changes its id.
+func StaticKey(alg, secret, privateKeyPath string) KeyLoader {
+	return func() ([]*Key, error) {
+		const op = "jwt.StaticKey"
+		method, err := signingMethod(alg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		var material []byte
+		if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+			if len(secret) == 0 {
+				return nil, fmt.Errorf("%s: secret is required for %s", op, alg)
+			}
+			material = []byte(secret)
+		} else {
+			if len(privateKeyPath) == 0 {
+				return nil, fmt.Errorf("%s: private key path is required for %s", op, alg)
+			}
+			if material, err = os.ReadFile(privateKeyPath); err != nil {
+				return nil, fmt.Errorf("%s - os.ReadFile: %w", op, err)
+			}
+		}
+
+		key, err := newKey(method, material)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		key.id, err = thumbprint(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return []*Key{key}, nil
+	}
+}
+
+// KeyDir loads every file of dir as a key, the file name without extension is the key id.
+// Files hold a PEM private key, or the raw secret for HMAC algorithms.
+// Keys are ordered by file name and the last one is used for signing,
+// so name them so that they sort by age, e.g. 2024-05-01.pem.
+func KeyDir(alg, dir string) KeyLoader {
+	return func() ([]*Key, error) {
+		const op = "jwt.KeyDir"
+		method, err := signingMethod(alg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("%s - os.ReadDir: %w", op, err)
+		}
+
+		var keys []*Key
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || strings.HasPrefix(name, ".") {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				return nil, fmt.Errorf("%s - os.ReadFile: %w", op, err)
+			}
+			if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+				data = bytes.TrimSpace(data)
+			}
+			key, err := newKey(method, data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", op, name, err)
+			}
+			key.id = strings.TrimSuffix(name, filepath.Ext(name))
+			keys = append(keys, key)
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("%s: no keys found in %s", op, dir)
+		}
+		return keys, nil
+	}
+}
+
+func signingMethod(alg string) (jwt.SigningMethod, error) {
+	method := jwt.GetSigningMethod(alg)
+	if method == nil || method == jwt.SigningMethodNone {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	return method, nil
+}
+
+func newKey(method jwt.SigningMethod, material []byte) (*Key, error) {
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		if len(material) == 0 {
+			return nil, fmt.Errorf("empty secret")
+		}
+		return &Key{method: method, signKey: material, verifyKey: material}, nil
+	}
+	return parsePrivateKey(method, material)
+}
+
+// thumbprint derives a short stable id from the verification key
+func thumbprint(key *Key) (string, error) {
+	data, ok := key.verifyKey.([]byte)
+	if !ok {
+		der, err := x509.MarshalPKIXPublicKey(key.verifyKey)
+		if err != nil {
+			return "", err
+		}
+		data = der
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
+
+// parsePrivateKey decodes a PEM private key and checks that it can be used with method
+func parsePrivateKey(method jwt.SigningMethod, data []byte) (*Key, error) {
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		private, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		if private.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits", minRSAKeyBits)
+		}
+		return &Key{method: method, signKey: private, verifyKey: &private.PublicKey}, nil
+	case *jwt.SigningMethodECDSA:
+		private, err := jwt.ParseECPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		if curve := ecdsaCurve(m); private.Curve != curve {
+			return nil, fmt.Errorf("%s requires curve %s, got %s", m.Alg(), curve.Params().Name, private.Curve.Params().Name)
+		}
+		return &Key{method: method, signKey: private, verifyKey: &private.PublicKey}, nil
+	case *jwt.SigningMethodEd25519:
+		private, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		return &Key{method: method, signKey: private, verifyKey: private.(ed25519.PrivateKey).Public()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", method.Alg())
+	}
+}
+
+func ecdsaCurve(m *jwt.SigningMethodECDSA) elliptic.Curve {
+	switch m.CurveBits {
+	case 384:
+		return elliptic.P384()
+	case 521:
+		return elliptic.P521()
+	default:
+		return elliptic.P256()
+	}
+}
+
+// ID returns the key id stamped into the kid header of tokens signed with the key
+func (k *Key) ID() string {
+	return k.id
+}
+
+// Alg returns the JWS algorithm name of the key
+func (k *Key) Alg() string {
+	return k.method.Alg()
+}
@@ -0,0 +1,191 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+)
+
+// writePrivateKey stores key as a PKCS #8 PEM file and returns its path
+func writePrivateKey(t *testing.T, key crypto.Signer) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func decodeInt(t *testing.T, s string) *big.Int {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+// TestStaticKeyAlgorithms signs and verifies a token with every kind of key
+// and checks that the published key matches the signing key
+func TestStaticKeyAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		alg   string
+		key   crypto.Signer
+		check func(t *testing.T, jwk models.JWK)
+	}{
+		{alg: "HS256"},
+		{alg: "RS256", key: rsaKey, check: func(t *testing.T, jwk models.JWK) {
+			if jwk.Kty != "RSA" || decodeInt(t, jwk.N).Cmp(rsaKey.N) != 0 || decodeInt(t, jwk.E).Int64() != int64(rsaKey.E) {
+				t.Errorf("jwk = %+v, want the RSA public key", jwk)
+			}
+		}},
+		{alg: "PS256", key: rsaKey, check: func(t *testing.T, jwk models.JWK) {
+			if jwk.Kty != "RSA" || jwk.Alg != "PS256" {
+				t.Errorf("jwk = %+v, want an RSA key for PS256", jwk)
+			}
+		}},
+		{alg: "ES256", key: p256Key, check: func(t *testing.T, jwk models.JWK) {
+			if jwk.Kty != "EC" || jwk.Crv != "P-256" || len(jwk.X) != 43 ||
+				decodeInt(t, jwk.X).Cmp(p256Key.X) != 0 || decodeInt(t, jwk.Y).Cmp(p256Key.Y) != 0 {
+				t.Errorf("jwk = %+v, want the P-256 public key with 32 byte coordinates", jwk)
+			}
+		}},
+		{alg: "ES384", key: p384Key, check: func(t *testing.T, jwk models.JWK) {
+			if jwk.Kty != "EC" || jwk.Crv != "P-384" || len(jwk.X) != 64 {
+				t.Errorf("jwk = %+v, want a P-384 key with 48 byte coordinates", jwk)
+			}
+		}},
+		{alg: "EdDSA", key: edKey, check: func(t *testing.T, jwk models.JWK) {
+			if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.X != base64.RawURLEncoding.EncodeToString(edKey.Public().(ed25519.PublicKey)) {
+				t.Errorf("jwk = %+v, want the Ed25519 public key", jwk)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			var path string
+			if tt.key != nil {
+				path = writePrivateKey(t, tt.key)
+			}
+			keyring, err := NewKeyring(StaticKey(tt.alg, "secret", path), time.Hour)
+			if err != nil {
+				t.Fatalf("NewKeyring() error = %v", err)
+			}
+			if alg := keyring.Active().Alg(); alg != tt.alg {
+				t.Errorf("Alg() = %s, want %s", alg, tt.alg)
+			}
+			tokens := New(keyring, "https://auth.example.com", []string{"api"}, time.Hour, 72*time.Hour)
+			token, err := tokens.NewAccessToken(testUser, "session")
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := tokens.Parse(token)
+			if err != nil || claims.UserId != testUser.Id {
+				t.Fatalf("Parse() = %+v, %v", claims, err)
+			}
+
+			set := tokens.JWKS()
+			if tt.check == nil {
+				if len(set.Keys) != 0 {
+					t.Errorf("JWKS() = %+v, HMAC secrets must not be published", set.Keys)
+				}
+				return
+			}
+			if len(set.Keys) != 1 || set.Keys[0].Kid != keyring.Active().ID() || set.Keys[0].Use != "sig" {
+				t.Fatalf("JWKS() = %+v, want the signing key", set.Keys)
+			}
+			tt.check(t, set.Keys[0])
+		})
+	}
+}
+
+func TestStaticKeyRejects(t *testing.T) {
+	smallRSA, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		alg    string
+		secret string
+		key    crypto.Signer
+	}{
+		{name: "none", alg: "none", secret: "secret"},
+		{name: "unknown algorithm", alg: "XS256", secret: "secret"},
+		{name: "HMAC without a secret", alg: "HS256"},
+		{name: "RSA without a key", alg: "RS256"},
+		{name: "short RSA key", alg: "RS256", key: smallRSA},
+		{name: "curve of another algorithm", alg: "ES256", key: p384Key},
+		{name: "key of another algorithm", alg: "EdDSA", key: p384Key},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			if tt.key != nil {
+				path = writePrivateKey(t, tt.key)
+			}
+			if _, err := StaticKey(tt.alg, tt.secret, path)(); err == nil {
+				t.Error("StaticKey() error = nil")
+			}
+		})
+	}
+}
+
+// TestParseRejectsOtherAlgorithm checks that a token signed with another key type
+// isn't accepted, even with a matching secret
+func TestParseRejectsOtherAlgorithm(t *testing.T) {
+	hs256, err := NewKeyring(StaticKey("HS256", "secret", ""), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs512, err := NewKeyring(StaticKey("HS512", "secret", ""), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := New(hs512, "https://auth.example.com", nil, time.Hour, time.Hour).NewAccessToken(testUser, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(hs256, "https://auth.example.com", nil, time.Hour, time.Hour).Parse(token); err == nil {
+		t.Error("Parse() error = nil for a token of another algorithm")
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+	"github.com/d1mitrii/authentication-service/internal/services/validation"
+	"github.com/d1mitrii/authentication-service/pkg/mailer"
+	"time"
+)
+
+type Option func(*Services)
+
+// SessionLimitPolicy decides what happens when a user logs in with every session slot taken
+type SessionLimitPolicy string
+
+const (
+	// SessionLimitReject refuses the new login
+	SessionLimitReject SessionLimitPolicy = "reject"
+	// SessionLimitEvictOldest ends the session created first
+	SessionLimitEvictOldest SessionLimitPolicy = "evict_oldest"
+	// SessionLimitEvictLRU ends the session refreshed least recently
+	SessionLimitEvictLRU SessionLimitPolicy = "evict_lru"
+)
+
+func ParseSessionLimitPolicy(policy string) (SessionLimitPolicy, error) {
+	switch p := SessionLimitPolicy(policy); p {
+	case SessionLimitReject, SessionLimitEvictOldest, SessionLimitEvictLRU:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown session limit policy %q", policy)
+	}
+}
+
+// SessionLimit caps the number of live sessions of one user, 0 means unlimited
+func SessionLimit(max int, policy SessionLimitPolicy) Option {
+	return func(s *Services) {
+		s.maxSessions = max
+		s.sessionPolicy = policy
+	}
+}
+
+// RememberMe sets the idle timeout of sessions started with "remember me",
+// 0 treats them as any other session
+func RememberMe(idle time.Duration) Option {
+	return func(s *Services) {
+		s.rememberMeTimeout = idle
+	}
+}
+
+// SessionTimeouts limits how long a session lives. Idle is the time it may stay unused,
+// 0 falls back to the refresh token TTL. Absolute counts from the login and holds across
+// refresh token rotation, after it the user has to log in again, 0 means no limit.
+func SessionTimeouts(idle, absolute time.Duration) Option {
+	return func(s *Services) {
+		s.idleTimeout = idle
+		s.maxLifetime = absolute
+	}
+}
+
+// PasswordPolicy sets the rules passwords of new users must follow
+func PasswordPolicy(policy validation.PasswordPolicy) Option {
+	return func(s *Services) {
+		s.passwordPolicy = policy
+	}
+}
+
+// Mailer sets how emails reach users, without it no email is sent
+func Mailer(m mailer.Mailer) Option {
+	return func(s *Services) {
+		s.mailer = m
+	}
+}
+
+// EmailVerification configures the verification emails: link is the page the token is appended to
+// as the "token" query parameter, ttl is how long the link works.
+// With required set users can't log in before verifying their email.
+func EmailVerification(link string, ttl time.Duration, required bool) Option {
+	return func(s *Services) {
+		s.verifyLink = link
+		s.verifyTTL = ttl
+		s.requireVerified = required
+	}
+}
+
+// PasswordReset configures the password reset emails: link is the page the token is appended to
+// as the "token" query parameter, ttl is how long the link works
+func PasswordReset(link string, ttl time.Duration) Option {
+	return func(s *Services) {
+		s.resetLink = link
+		s.resetTTL = ttl
+	}
+}
+
+// Clock sets the source of the current time, tests use it to move time forward
+func Clock(now func() time.Time) Option {
+	return func(s *Services) {
+		s.now = now
+	}
+}
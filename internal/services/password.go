@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
+	"github.com/d1mitrii/authentication-service/internal/services/validation"
+	"github.com/d1mitrii/authentication-service/pkg/mailer"
+	"log/slog"
+	"time"
+)
+
+const defaultResetTTL = 30 * time.Minute
+
+// RequestPasswordReset mails a password reset link, earlier links stop working.
+// The lookup and the email happen in the background and unknown emails are ignored,
+// so neither the answer nor its timing tells whether an account exists.
+func (s *Services) RequestPasswordReset(ctx context.Context, email string) error {
+	const op = "Services.RequestPasswordReset"
+	log := s.log.With(
+		slog.String("operation", op),
+		slog.String("email", email),
+	)
+	if s.mailer == nil {
+		log.Warn("no mailer configured, password reset is unavailable")
+		return nil
+	}
+	s.background(ctx, func(ctx context.Context) {
+		user, err := s.userByEmail(ctx, email)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				log.Info("user not found")
+				return
+			}
+			log.Error("failed to get user", slog.String("error", err.Error()))
+			return
+		}
+		log := log.With(slog.Int("user-id", user.Id))
+		if err := s.sendPasswordReset(ctx, user); err != nil {
+			log.Error("failed to send password reset email", slog.String("error", err.Error()))
+			return
+		}
+		log.Info("password reset email sent")
+	})
+	return nil
+}
+
+// ResetPassword sets a new password for the user of a reset token and ends all sessions
+// and access tokens of the user.
+// The password is checked against the policy before the token is used up,
+// a rejected password comes back as *validation.Error and the link keeps working.
+func (s *Services) ResetPassword(ctx context.Context, token string, password string) error {
+	const op = "Services.ResetPassword"
+	log := s.log.With(slog.String("operation", op))
+	tokenDigest := digest(token)
+	stored, err := s.repo.OneTimeToken.GetToken(ctx, models.PurposePasswordReset, tokenDigest)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			log.Info("unknown password reset token")
+			return ErrInvalidResetToken
+		}
+		log.Error("failed to get password reset token", slog.String("error", err.Error()))
+		return err
+	}
+	log = log.With(slog.Int("user-id", stored.UserId))
+	user, err := s.repo.User.GetUserById(ctx, stored.UserId)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			log.Warn("user not found")
+			return ErrInvalidResetToken
+		}
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return err
+	}
+	if err := s.passwordPolicy.ValidatePassword(password, user.Email); err != nil {
+		var invalid *validation.Error
+		if !errors.As(err, &invalid) {
+			log.Error("failed to validate password", slog.String("error", err.Error()))
+			return err
+		}
+		log.Info("invalid password", slog.String("error", err.Error()))
+		return err
+	}
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Error("failed to hash password", slog.String("error", err.Error()))
+		return ErrHashing
+	}
+
+	// Consuming is atomic, of two concurrent resets with the same token only one gets here
+	if _, err := s.repo.OneTimeToken.ConsumeToken(ctx, models.PurposePasswordReset, tokenDigest); err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			log.Info("password reset token already used")
+			return ErrInvalidResetToken
+		}
+		log.Error("failed to consume password reset token", slog.String("error", err.Error()))
+		return err
+	}
+	if err := s.repo.User.UpdatePassword(ctx, user.Id, hash); err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			log.Warn("user not found")
+			return ErrInvalidResetToken
+		}
+		log.Error("failed to update password", slog.String("error", err.Error()))
+		return err
+	}
+	log.Info("password reset")
+
+	if err := s.repo.OneTimeToken.DeleteUserTokens(ctx, user.Id, models.PurposePasswordReset); err != nil {
+		log.Error("failed to delete password reset tokens", slog.String("error", err.Error()))
+	}
+	if err := s.repo.RefreshSession.RevokeAllSessions(ctx, user.Id); err != nil {
+		log.Error("failed to revoke sessions", slog.String("error", err.Error()))
+		return err
+	}
+	if err := s.revokeUserTokens(ctx, log, user.Id); err != nil {
+		return err
+	}
+	// The link was delivered to the email, which proves the user owns it
+	if !user.EmailVerified() {
+		if err := s.repo.User.MarkEmailVerified(ctx, user.Id, s.now()); err != nil {
+			log.Error("failed to mark email verified", slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+// ChangePassword replaces the password of a logged in user after checking the current one
+// and ends the other sessions of the user together with their access tokens.
+// The session of keepRefreshToken stays alive, with an empty token every session ends.
+func (s *Services) ChangePassword(ctx context.Context, userId int, currentPassword, newPassword string, keepRefreshToken string) error {
+	const op = "Services.ChangePassword"
+	log := s.log.With(
+		slog.String("operation", op),
+		slog.Int("user-id", userId),
+	)
+	user, err := s.repo.User.GetUserById(ctx, userId)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			log.Warn("user not found")
+			return ErrUserNotFound
+		}
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return err
+	}
+	if !s.hasher.Compare(currentPassword, user.Password) {
+		log.Info("incorrect current password")
+		return ErrIncorrectPassword
+	}
+	if err := s.passwordPolicy.ValidatePassword(newPassword, user.Email); err != nil {
+		var invalid *validation.Error
+		if !errors.As(err, &invalid) {
+			log.Error("failed to validate password", slog.String("error", err.Error()))
+			return err
+		}
+		log.Info("invalid password", slog.String("error", err.Error()))
+		return err
+	}
+	hash, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		log.Error("failed to hash password", slog.String("error", err.Error()))
+		return ErrHashing
+	}
+	if err := s.repo.User.UpdatePassword(ctx, user.Id, hash); err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			log.Warn("user not found")
+			return ErrUserNotFound
+		}
+		log.Error("failed to update password", slog.String("error", err.Error()))
+		return err
+	}
+	log.Info("password changed")
+
+	// A pending reset link would otherwise still undo the change
+	if err := s.repo.OneTimeToken.DeleteUserTokens(ctx, user.Id, models.PurposePasswordReset); err != nil {
+		log.Error("failed to delete password reset tokens", slog.String("error", err.Error()))
+	}
+	return s.revokeOtherSessions(ctx, log, user.Id, keepRefreshToken)
+}
+
+// revokeOtherSessions ends every session of the user and its access tokens except the one of refreshToken,
+// a token of another user or of no live session keeps nothing
+func (s *Services) revokeOtherSessions(ctx context.Context, log *slog.Logger, userId int, refreshToken string) error {
+	keep := ""
+	if refreshToken != "" {
+		session, err := s.repo.RefreshSession.GetSession(ctx, digest(refreshToken))
+		switch {
+		case err == nil && session.UserId == userId:
+			keep = session.Id
+		case err != nil && !errors.Is(err, repoerrors.ErrNotFound):
+			log.Error("failed to get refresh session", slog.String("error", err.Error()))
+			return err
+		}
+	}
+	if keep == "" {
+		if err := s.repo.RefreshSession.RevokeAllSessions(ctx, userId); err != nil {
+			log.Error("failed to revoke sessions", slog.String("error", err.Error()))
+			return err
+		}
+		if err := s.revokeUserTokens(ctx, log, userId); err != nil {
+			return err
+		}
+		log.Info("all sessions revoked")
+		return nil
+	}
+	sessions, err := s.repo.RefreshSession.ListSessions(ctx, userId)
+	if err != nil {
+		log.Error("failed to list sessions", slog.String("error", err.Error()))
+		return err
+	}
+	for _, session := range sessions {
+		if session.Id == keep {
+			continue
+		}
+		err := s.repo.RefreshSession.RevokeSession(ctx, userId, session.Id)
+		if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+			log.Error("failed to revoke session", slog.String("error", err.Error()))
+			return err
+		}
+		if err := s.revokeSessionTokens(ctx, log, session.Id); err != nil {
+			return err
+		}
+	}
+	log.Info("other sessions revoked", slog.String("session-id", keep))
+	return nil
+}
+
+// sendPasswordReset replaces the reset tokens of the user with a new one and mails it
+func (s *Services) sendPasswordReset(ctx context.Context, user models.User) error {
+	token, err := newOneTimeToken()
+	if err != nil {
+		return fmt.Errorf("newOneTimeToken: %v", err)
+	}
+	link, err := withToken(s.resetLink, token)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.OneTimeToken.DeleteUserTokens(ctx, user.Id, models.PurposePasswordReset); err != nil {
+		return err
+	}
+	now := s.now()
+	err = s.repo.OneTimeToken.CreateToken(ctx, digest(token), models.OneTimeToken{
+		Purpose:   models.PurposePasswordReset,
+		UserId:    user.Id,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.resetTTL),
+	})
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Choose a new password by opening the link below:\n\n%s\n\n"+
+			"The link is valid for %s and works once. If you didn't ask to reset your password, ignore this email.\n",
+			link, s.resetTTL),
+	})
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/services/validation"
+)
+
+const newPassword = "battery staple"
+
+func TestPasswordReset(t *testing.T) {
+	t.Parallel()
+	s, box := newTestServices(t)
+	id := register(t, s, testEmail, testPassword)
+	ctx := context.Background()
+	s.Wait()
+	verificationEmails := len(box.messages())
+
+	if err := s.RequestPasswordReset(ctx, "bob@example.com"); err != nil {
+		t.Fatalf("RequestPasswordReset() of an unknown email error = %v", err)
+	}
+	s.Wait()
+	if sent := len(box.messages()); sent != verificationEmails {
+		t.Fatalf("%d emails sent for an unknown email", sent-verificationEmails)
+	}
+
+	session := login(t, s, testEmail, testPassword)
+	if err := s.RequestPasswordReset(ctx, testEmail); err != nil {
+		t.Fatalf("RequestPasswordReset() error = %v", err)
+	}
+	earlier := linkToken(t, s, box)
+	if err := s.RequestPasswordReset(ctx, testEmail); err != nil {
+		t.Fatal(err)
+	}
+	token := linkToken(t, s, box)
+	if sent := box.messages(); sent[len(sent)-1].To != testEmail {
+		t.Errorf("reset email sent to %q, want %q", sent[len(sent)-1].To, testEmail)
+	}
+	if err := s.ResetPassword(ctx, earlier, newPassword); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("ResetPassword() with a replaced link error = %v, want %v", err, ErrInvalidResetToken)
+	}
+
+	// a rejected password leaves the link working
+	var invalid *validation.Error
+	if err := s.ResetPassword(ctx, token, "short"); !errors.As(err, &invalid) {
+		t.Fatalf("ResetPassword() with a weak password error = %v, want *validation.Error", err)
+	}
+	if err := s.ResetPassword(ctx, token, newPassword); err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+	if err := s.ResetPassword(ctx, token, newPassword); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("ResetPassword() with a used link error = %v, want %v", err, ErrInvalidResetToken)
+	}
+
+	if _, err := s.RefreshSession(ctx, session.Refresh, testClient); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RefreshSession() after reset error = %v, want %v", err, ErrSessionNotFound)
+	}
+	if _, err := s.Authenticate(ctx, session.Access); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Authenticate() after reset error = %v, want %v", err, ErrTokenRevoked)
+	}
+	_, err := s.Login(ctx, models.User{Email: testEmail, Password: testPassword}, testClient, false)
+	if !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("Login() with the old password error = %v, want %v", err, ErrIncorrectPassword)
+	}
+	login(t, s, testEmail, newPassword)
+
+	// the link reached the mailbox, so the email is verified
+	user, err := s.repo.User.GetUserById(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.EmailVerified() {
+		t.Error("email not verified after a password reset")
+	}
+}
+
+func TestResetPasswordUnknownToken(t *testing.T) {
+	s, _ := newTestServices(t)
+	if err := s.ResetPassword(context.Background(), "unknown", newPassword); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("ResetPassword() error = %v, want %v", err, ErrInvalidResetToken)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	t.Parallel()
+	s, _ := newTestServices(t)
+	id := register(t, s, testEmail, testPassword)
+	ctx := context.Background()
+	current := login(t, s, testEmail, testPassword)
+	other := login(t, s, testEmail, testPassword)
+
+	if err := s.ChangePassword(ctx, id, "wrong horse", newPassword, current.Refresh); !errors.Is(err, ErrIncorrectPassword) {
+		t.Fatalf("ChangePassword() with a wrong password error = %v, want %v", err, ErrIncorrectPassword)
+	}
+	var invalid *validation.Error
+	if err := s.ChangePassword(ctx, id, testPassword, "ann-secret", current.Refresh); !errors.As(err, &invalid) {
+		t.Fatalf("ChangePassword() with a password containing the email error = %v, want *validation.Error", err)
+	}
+	if err := s.ChangePassword(ctx, id+1, testPassword, newPassword, current.Refresh); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("ChangePassword() of an unknown user error = %v, want %v", err, ErrUserNotFound)
+	}
+
+	if err := s.ChangePassword(ctx, id, testPassword, newPassword, current.Refresh); err != nil {
+		t.Fatalf("ChangePassword() error = %v", err)
+	}
+	if _, err := s.Authenticate(ctx, current.Access); err != nil {
+		t.Errorf("Authenticate() of the kept session error = %v", err)
+	}
+	current, err := s.RefreshSession(ctx, current.Refresh, testClient)
+	if err != nil {
+		t.Errorf("RefreshSession() of the kept session error = %v", err)
+	}
+	if _, err := s.Authenticate(ctx, other.Access); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Authenticate() of another session error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err := s.RefreshSession(ctx, other.Refresh, testClient); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RefreshSession() of another session error = %v, want %v", err, ErrSessionNotFound)
+	}
+	login(t, s, testEmail, newPassword)
+
+	// without a session to keep every session ends
+	if err := s.ChangePassword(ctx, id, newPassword, testPassword, ""); err != nil {
+		t.Fatalf("ChangePassword() error = %v", err)
+	}
+	if _, err := s.Authenticate(ctx, current.Access); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Authenticate() after ending every session error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err := s.RefreshSession(ctx, current.Refresh, testClient); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RefreshSession() after ending every session error = %v, want %v", err, ErrSessionNotFound)
+	}
+}
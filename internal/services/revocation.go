@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+)
+
+// Access tokens are revoked in bulk with "issued before" markers on the user or on the session
+// they were issued for (the sid claim), the markers live as long as an access token
+func userSubject(userId int) string {
+	return "user:" + strconv.Itoa(userId)
+}
+
+func sessionSubject(sessionId string) string {
+	return "session:" + sessionId
+}
+
+// revokedByMarker reports whether the token was issued before a revocation of its user or session
+func (s *Services) revokedByMarker(ctx context.Context, claims models.Claims) (bool, error) {
+	subjects := []string{userSubject(claims.UserId)}
+	if claims.SessionId != "" {
+		subjects = append(subjects, sessionSubject(claims.SessionId))
+	}
+	for _, subject := range subjects {
+		before, err := s.repo.Denylist.RevokedBefore(ctx, subject)
+		if err != nil {
+			return false, err
+		}
+		if claims.IssuedAt.Before(before) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// revokeUserTokens ends every access token of the user issued so far,
+// a login right after it gets a token with a later iat that stays valid
+func (s *Services) revokeUserTokens(ctx context.Context, log *slog.Logger, userId int) error {
+	before := s.now()
+	if err := s.repo.Denylist.RevokeBefore(ctx, userSubject(userId), before, s.revocationTTL()); err != nil {
+		log.Error("failed to revoke access tokens of the user", slog.String("error", err.Error()))
+		return err
+	}
+	return nil
+}
+
+// revokeSessionTokens ends every access token of the session issued so far
+func (s *Services) revokeSessionTokens(ctx context.Context, log *slog.Logger, sessionId string) error {
+	before := s.now()
+	if err := s.repo.Denylist.RevokeBefore(ctx, sessionSubject(sessionId), before, s.revocationTTL()); err != nil {
+		log.Error("failed to revoke access tokens of the session", slog.String("error", err.Error()))
+		return err
+	}
+	return nil
+}
+
+// revocationTTL outlives every access token issued before now
+func (s *Services) revocationTTL() time.Duration {
+	return s.JWT.AccessTTL() + time.Second
+}
@@ -2,19 +2,32 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"github.com/d1mitrii/authentication-service/internal/models"
 	"github.com/d1mitrii/authentication-service/internal/repository"
 	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
+	"github.com/d1mitrii/authentication-service/internal/services/validation"
+	"github.com/d1mitrii/authentication-service/pkg/mailer"
 	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 type JWT interface {
-	NewAccessToken(models.User) (string, error)
+	NewAccessToken(user models.User, sessionId string) (string, error)
 	NewRefreshToken() (string, error)
+	AccessTTL() time.Duration
 	RefreshTTL() time.Duration
-	Parse(string) (int, error)
+	Parse(string) (models.Claims, error)
+	JWKS() models.JWKSet
+	Algorithm() string
 }
 
 type Hasher interface {
@@ -27,24 +40,84 @@ type Services struct {
 	JWT    JWT
 	hasher Hasher
 	repo   *repository.Repositories
+
+	maxSessions   int
+	sessionPolicy SessionLimitPolicy
+	idleTimeout   time.Duration
+	maxLifetime   time.Duration
+
+	rememberMeTimeout time.Duration
+
+	passwordPolicy validation.PasswordPolicy
+
+	mailer          mailer.Mailer
+	verifyLink      string
+	verifyTTL       time.Duration
+	requireVerified bool
+
+	resetLink string
+	resetTTL  time.Duration
+
+	// jobs tracks the work that outlives its request, like sending emails
+	jobs sync.WaitGroup
+
+	now func() time.Time
 }
 
-func New(log *slog.Logger, jwt JWT, hasher Hasher, repo *repository.Repositories) *Services {
-	return &Services{
-		log:    log,
-		JWT:    jwt,
-		hasher: hasher,
-		repo:   repo,
+func New(log *slog.Logger, jwt JWT, hasher Hasher, repo *repository.Repositories, opts ...Option) *Services {
+	s := &Services{
+		log:           log,
+		JWT:           jwt,
+		hasher:        hasher,
+		repo:          repo,
+		sessionPolicy: SessionLimitReject,
+
+		passwordPolicy: validation.DefaultPasswordPolicy(),
+
+		verifyTTL: defaultVerifyTTL,
+		resetTTL:  defaultResetTTL,
+
+		now: time.Now,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Wait blocks until the work started in the background is done, call it on shutdown
+func (s *Services) Wait() {
+	s.jobs.Wait()
+}
+
+// background runs job after the request has been answered,
+// so slow mail servers don't delay the answer and its timing tells nothing
+func (s *Services) background(ctx context.Context, job func(ctx context.Context)) {
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		job(context.WithoutCancel(ctx))
+	}()
 }
 
+// Register creates a user with a normalized email,
+// input that breaks the password policy is rejected with *validation.Error
 func (s *Services) Register(ctx context.Context, user models.User) (int, error) {
-	// TODO: Add user info validation
 	const op = "Services.Register"
 	log := s.log.With(
 		slog.String("operation", op),
 		slog.String("email", user.Email),
 	)
+	user, err := validation.Registration(user, s.passwordPolicy)
+	if err != nil {
+		var invalid *validation.Error
+		if !errors.As(err, &invalid) {
+			log.Error("failed to validate user", slog.String("error", err.Error()))
+			return 0, err
+		}
+		log.Info("invalid user", slog.String("error", err.Error()))
+		return 0, err
+	}
 	hash, err := s.hasher.Hash(user.Password)
 	if err != nil {
 		log.Info("invalid password")
@@ -60,16 +133,27 @@ func (s *Services) Register(ctx context.Context, user models.User) (int, error)
 		log.Warn("failed to create user", slog.String("error", err.Error()))
 		return 0, err
 	}
+	user.Id = id
+	s.background(ctx, func(ctx context.Context) {
+		if err := s.sendVerification(ctx, user); err != nil {
+			// the user can ask for another link
+			log.Error("failed to send verification email", slog.String("error", err.Error()))
+		}
+	})
 	return id, nil
 }
 
-func (s *Services) Login(ctx context.Context, user models.User) (models.Token, error) {
+// Login starts a new session, rememberMe makes it persistent: it gets the long idle timeout
+// and keeps it across refresh token rotation
+func (s *Services) Login(ctx context.Context, user models.User, client models.ClientInfo, rememberMe bool) (models.Token, error) {
 	const op = "Services.Login"
+	client = sanitizeClient(client)
 	log := s.log.With(
 		slog.String("operation", op),
 		slog.String("email", user.Email),
+		clientAttr(client),
 	)
-	userFromDB, err := s.repo.User.GetUserByEmail(ctx, user.Email)
+	userFromDB, err := s.userByEmail(ctx, user.Email)
 	if err != nil {
 		if errors.Is(err, repoerrors.ErrNotFound) {
 			log.Warn("user not found", slog.String("error", err.Error()))
@@ -83,67 +167,338 @@ func (s *Services) Login(ctx context.Context, user models.User) (models.Token, e
 		log.Info("invalid password")
 		return models.Token{}, ErrIncorrectPassword
 	}
+	if s.requireVerified && !userFromDB.EmailVerified() {
+		log.Info("email not verified")
+		return models.Token{}, ErrEmailNotVerified
+	}
 
-	return s.generateJWT(ctx, userFromDB)
+	sessionId, err := newSessionId()
+	if err != nil {
+		log.Warn("failed to generate session id", slog.String("error", err.Error()))
+		return models.Token{}, ErrSessionCreateFail
+	}
+	session := models.RefreshSession{
+		Id:         sessionId,
+		CreatedAt:  s.now(),
+		Persistent: rememberMe,
+	}
+	token, err := s.generateJWT(ctx, userFromDB, session, client, "")
+	if err != nil {
+		return models.Token{}, err
+	}
+	log.Info("user logged in", slog.String("session-id", sessionId))
+	return token, nil
 }
 
-func (s *Services) Logout(ctx context.Context, refreshToken string) error {
+func (s *Services) Logout(ctx context.Context, refreshToken string, accessToken string) error {
 	const op = "Services.Logout"
-	log := slog.With(
+	tokenDigest := digest(refreshToken)
+	log := s.log.With(
 		slog.String("operation", op),
-		slog.With("refresh-token", refreshToken),
+		slog.String("refresh-digest", tokenDigest),
 	)
-	if _, err := s.repo.RefreshSession.DeleteSession(ctx, refreshToken); err != nil {
+	session, err := s.repo.RefreshSession.DeleteSession(ctx, tokenDigest)
+	if err != nil {
 		log.Error("failed to get-delete refresh session", slog.String("error", err.Error()))
 		return err
 	}
+	err = s.repo.RefreshSession.RevokeSession(ctx, session.UserId, session.Id)
+	if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+		log.Error("failed to revoke session", slog.String("error", err.Error()))
+		return err
+	}
+	if err := s.revokeSessionTokens(ctx, log, session.Id); err != nil {
+		return err
+	}
+	if len(accessToken) != 0 {
+		if claims, err := s.JWT.Parse(accessToken); err == nil {
+			if err := s.RevokeAccessToken(ctx, claims); err != nil {
+				return err
+			}
+		}
+	}
 	log.Info("success logout")
 	return nil
 }
 
-func (s *Services) generateJWT(ctx context.Context, user models.User) (models.Token, error) {
+// Authenticate verifies the access token and checks that it wasn't revoked
+func (s *Services) Authenticate(ctx context.Context, accessToken string) (models.Claims, error) {
+	const op = "Services.Authenticate"
+	claims, err := s.JWT.Parse(accessToken)
+	if err != nil {
+		return models.Claims{}, rejectionReason(err)
+	}
+	revoked, err := s.repo.Denylist.Contains(ctx, claims.ID)
+	if err != nil {
+		s.log.Error("failed to check token denylist",
+			slog.String("operation", op),
+			slog.String("error", err.Error()),
+		)
+		return models.Claims{}, err
+	}
+	if revoked {
+		return models.Claims{}, ErrTokenRevoked
+	}
+	revoked, err = s.revokedByMarker(ctx, claims)
+	if err != nil {
+		s.log.Error("failed to check token revocations",
+			slog.String("operation", op),
+			slog.String("error", err.Error()),
+		)
+		return models.Claims{}, err
+	}
+	if revoked {
+		return models.Claims{}, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// RevokeAccessToken denylists the access token until it expires
+func (s *Services) RevokeAccessToken(ctx context.Context, claims models.Claims) error {
+	const op = "Services.RevokeAccessToken"
+	log := s.log.With(
+		slog.String("operation", op),
+		slog.Int("user-id", claims.UserId),
+		slog.String("jti", claims.ID),
+	)
+	if err := s.repo.Denylist.Add(ctx, claims.ID, claims.ExpiresAt.Sub(s.now())); err != nil {
+		log.Error("failed to revoke access token", slog.String("error", err.Error()))
+		return err
+	}
+	log.Info("access token revoked")
+	return nil
+}
+
+// UserInfo returns OpenID Connect claims of the user
+func (s *Services) UserInfo(ctx context.Context, userId int) (models.UserInfo, error) {
+	const op = "Services.UserInfo"
+	log := s.log.With(
+		slog.String("operation", op),
+		slog.Int("user-id", userId),
+	)
+	user, err := s.repo.User.GetUserById(ctx, userId)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			log.Warn(err.Error())
+			return models.UserInfo{}, ErrUserNotFound
+		}
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		return models.UserInfo{}, err
+	}
+	return models.UserInfo{
+		Subject:       strconv.Itoa(user.Id),
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified(),
+	}, nil
+}
+
+// JWKS returns the public keys that verify access tokens
+func (s *Services) JWKS() models.JWKSet {
+	return s.JWT.JWKS()
+}
+
+// generateJWT issues a token pair and stores the refresh token as the live token of the session.
+// rotated is the digest of the token being exchanged, a login that starts the session passes none.
+func (s *Services) generateJWT(ctx context.Context, user models.User, session models.RefreshSession, client models.ClientInfo, rotated string) (models.Token, error) {
 	const op = "Services.generateJWT"
 	log := s.log.With(
 		slog.String("operation", op),
 		slog.String("email", user.Email),
 	)
-	access, errAccess := s.JWT.NewAccessToken(user)
+	access, errAccess := s.JWT.NewAccessToken(user, session.Id)
 	refresh, errRefresh := s.JWT.NewRefreshToken()
 	if errAccess != nil || errRefresh != nil {
 		log.Warn("failed to sign token")
 		return models.Token{}, ErrCannotSignToken
 	}
 
-	if err := s.repo.RefreshSession.CreateSession(ctx, refresh, user.Id); err != nil {
+	if rotated == "" {
+		if err := s.enforceSessionLimit(ctx, log, user.Id); err != nil {
+			return models.Token{}, err
+		}
+	}
+
+	now := s.now()
+	session.UserId = user.Id
+	session.LastUsedAt = now
+	session.IP = client.IP
+	session.UserAgent = client.UserAgent
+	session.DeviceName = client.DeviceName
+	session.ExpiresAt = s.sessionExpiry(session, now)
+	if rotated == "" {
+		if err := s.repo.RefreshSession.CreateSession(ctx, digest(refresh), session); err != nil {
+			log.Error("failed to create refresh session", slog.String("error", err.Error()))
+			return models.Token{}, ErrSessionCreateFail
+		}
+	} else if err := s.repo.RefreshSession.RotateSession(ctx, rotated, digest(refresh), session); err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return models.Token{}, ErrSessionNotFound
+		}
+		log.Error("failed to rotate refresh session", slog.String("error", err.Error()))
 		return models.Token{}, ErrSessionCreateFail
 	}
-	return models.Token{Access: access, Refresh: refresh}, nil
+	return models.Token{
+		Access:           access,
+		Refresh:          refresh,
+		RefreshExpiresAt: session.ExpiresAt,
+		Persistent:       session.Persistent,
+	}, nil
 }
 
-func (s *Services) RefreshSession(ctx context.Context, refreshToken string) (models.Token, error) {
+func (s *Services) RefreshSession(ctx context.Context, refreshToken string, client models.ClientInfo) (models.Token, error) {
 	const op = "Services.RefreshSession"
+	client = sanitizeClient(client)
+	tokenDigest := digest(refreshToken)
 	log := s.log.With(
 		slog.String("operation", op),
-		slog.String("refresh-token", refreshToken),
+		slog.String("refresh-digest", tokenDigest),
+		clientAttr(client),
 	)
-	userId, err := s.repo.RefreshSession.DeleteSession(ctx, refreshToken)
+	session, err := s.repo.RefreshSession.GetSession(ctx, tokenDigest)
 	if err != nil {
 		if errors.Is(err, repoerrors.ErrNotFound) {
 			log.Warn(err.Error())
+			if err := s.detectReuse(ctx, log, tokenDigest); err != nil {
+				return models.Token{}, err
+			}
 			return models.Token{}, ErrSessionNotFound
 		}
-		log.Error("failed to get-delete refresh session", slog.String("error", err.Error()))
+		log.Error("failed to get refresh session", slog.String("error", err.Error()))
 		return models.Token{}, err
 	}
-
-	user, err := s.repo.User.GetUserById(ctx, userId)
+	if s.sessionExpired(session, s.now()) {
+		log.Info("refresh session expired, re-authentication required",
+			slog.String("session-id", session.Id),
+			slog.Time("created-at", session.CreatedAt),
+			slog.Time("last-used-at", session.LastUsedAt),
+		)
+		err := s.repo.RefreshSession.RevokeSession(ctx, session.UserId, session.Id)
+		if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+			log.Error("failed to revoke session", slog.String("error", err.Error()))
+		}
+		return models.Token{}, ErrSessionExpired
+	}
+	user, err := s.repo.User.GetUserById(ctx, session.UserId)
 	if err != nil {
 		if errors.Is(err, repoerrors.ErrNotFound) {
 			log.Warn(err.Error())
 			return models.Token{}, ErrUserNotFound
 		}
 		log.Warn("failed to get user", slog.String("error", err.Error()))
-		return models.Token{}, nil
+		return models.Token{}, err
+	}
+	if len(session.IP) != 0 && (session.IP != client.IP || session.UserAgent != client.UserAgent) {
+		log.Info("session used from another client",
+			slog.String("event", "session_client_changed"),
+			slog.String("session-id", session.Id),
+			slog.Group("previous-client",
+				slog.String("ip", session.IP),
+				slog.String("user-agent", session.UserAgent),
+			),
+		)
+	}
+
+	// marked before the rotation: if the mark can't be written the token stays live and the client may retry
+	if err := s.repo.RefreshSession.MarkSpent(ctx, tokenDigest, session); err != nil {
+		log.Error("failed to mark refresh token as spent", slog.String("error", err.Error()))
+		return models.Token{}, err
+	}
+	token, err := s.generateJWT(ctx, user, session, client, tokenDigest)
+	if errors.Is(err, ErrSessionNotFound) {
+		// a concurrent refresh or revocation got to the session first
+		log.Warn("refresh session changed during rotation", slog.String("session-id", session.Id))
+		if err := s.detectReuse(ctx, log, tokenDigest); err != nil {
+			return models.Token{}, err
+		}
+	}
+	return token, err
+}
+
+// detectReuse revokes the whole session when an already rotated refresh token is presented.
+// Either the legitimate client or an attacker holds a copy of the token,
+// so the live token of the session can't be trusted anymore.
+// An error means the session may still be live, the caller must not report a plain miss.
+func (s *Services) detectReuse(ctx context.Context, log *slog.Logger, tokenDigest string) error {
+	session, err := s.repo.RefreshSession.GetSpent(ctx, tokenDigest)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return nil
+		}
+		log.Error("failed to check spent refresh tokens", slog.String("error", err.Error()))
+		return err
+	}
+	log.Warn("security event: refresh token reuse detected, revoking session",
+		slog.String("event", "refresh_token_reuse"),
+		slog.Int("user-id", session.UserId),
+		slog.String("session-id", session.Id),
+		slog.Group("session-client",
+			slog.String("ip", session.IP),
+			slog.String("user-agent", session.UserAgent),
+		),
+	)
+	err = s.repo.RefreshSession.RevokeSession(ctx, session.UserId, session.Id)
+	if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+		log.Error("failed to revoke session", slog.String("error", err.Error()))
+		return err
+	}
+	return s.revokeSessionTokens(ctx, log, session.Id)
+}
+
+// userByEmail looks the user up by the normalized email and then by the email as entered,
+// accounts registered before emails were normalized are stored the way they were typed
+func (s *Services) userByEmail(ctx context.Context, email string) (models.User, error) {
+	normalized, err := validation.NormalizeEmail(email)
+	if err == nil {
+		user, err := s.repo.User.GetUserByEmail(ctx, normalized)
+		if normalized == email || !errors.Is(err, repoerrors.ErrNotFound) {
+			return user, err
+		}
+	}
+	return s.repo.User.GetUserByEmail(ctx, email)
+}
+
+// digest is the SHA-256 of a refresh token, only digests are kept in the session store
+func digest(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// Client values come from the caller and are capped before they are stored
+const (
+	maxUserAgentLen  = 512
+	maxDeviceNameLen = 128
+)
+
+func sanitizeClient(client models.ClientInfo) models.ClientInfo {
+	client.UserAgent = truncate(client.UserAgent, maxUserAgentLen)
+	client.DeviceName = truncate(client.DeviceName, maxDeviceNameLen)
+	return client
+}
+
+func truncate(s string, n int) string {
+	s = strings.ToValidUTF8(s, "")
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+func clientAttr(client models.ClientInfo) slog.Attr {
+	return slog.Group("client",
+		slog.String("ip", client.IP),
+		slog.String("user-agent", client.UserAgent),
+		slog.String("device", client.DeviceName),
+	)
+}
+
+func newSessionId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
-	return s.generateJWT(ctx, user)
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/metrics"
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/repository"
+	"github.com/d1mitrii/authentication-service/internal/repository/memory"
+	"github.com/d1mitrii/authentication-service/internal/services/jwt"
+	"github.com/d1mitrii/authentication-service/internal/services/validation"
+	"github.com/d1mitrii/authentication-service/pkg/hasher"
+	"github.com/d1mitrii/authentication-service/pkg/mailer"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testEmail    = "ann@example.com"
+	testPassword = "correct horse"
+)
+
+var testClient = models.ClientInfo{IP: "192.0.2.1", UserAgent: "test", DeviceName: "laptop"}
+
+func TestMain(m *testing.M) {
+	if err := metrics.Init(prometheus.NewRegistry()); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// outbox is a mailer that keeps the messages
+type outbox struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (o *outbox) Send(ctx context.Context, msg mailer.Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+func (o *outbox) messages() []mailer.Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]mailer.Message(nil), o.sent...)
+}
+
+// clock is the time of the services and their tokens, tests move it instead of sleeping.
+// Every reading moves it a microsecond on, so a revocation is always later than the tokens
+// issued before it. It starts at the real time, the memory repositories check expiry against the wall clock.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newClock() *clock {
+	return &clock{now: time.Now()}
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(time.Microsecond)
+	return c.now
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newTestServices builds the services on the memory repositories,
+// opts are applied after the defaults
+func newTestServices(t *testing.T, opts ...Option) (*Services, *outbox) {
+	t.Helper()
+	return newTestServicesAt(t, newClock(), opts...)
+}
+
+// newTestServicesAt builds the services with the time taken from clock
+func newTestServicesAt(t *testing.T, clock *clock, opts ...Option) (*Services, *outbox) {
+	t.Helper()
+	keyring, err := jwt.NewKeyring(jwt.StaticKey("HS256", "secret", ""), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := jwt.New(keyring, "https://auth.example.com", []string{"api"}, time.Hour, 72*time.Hour, jwt.Clock(clock.Now))
+	repo := repository.New(
+		memory.NewUserRepo(),
+		memory.NewOneTimeTokenRepo(),
+		memory.NewRefreshRepo(72*time.Hour),
+		memory.NewTokenDenylist(),
+	)
+	box := &outbox{}
+	opts = append([]Option{
+		Clock(clock.Now),
+		Mailer(box),
+		EmailVerification("https://app.example.com/verify", time.Hour, false),
+		PasswordReset("https://app.example.com/reset", time.Hour),
+	}, opts...)
+	s := New(slog.New(slog.NewTextHandler(io.Discard, nil)), tokens, hasher.New(bcrypt.MinCost), repo, opts...)
+	t.Cleanup(s.Wait)
+	return s, box
+}
+
+func register(t *testing.T, s *Services, email, password string) int {
+	t.Helper()
+	id, err := s.Register(context.Background(), models.User{Email: email, Password: password})
+	if err != nil {
+		t.Fatalf("Register(%q) error = %v", email, err)
+	}
+	return id
+}
+
+func login(t *testing.T, s *Services, email, password string) models.Token {
+	t.Helper()
+	token, err := s.Login(context.Background(), models.User{Email: email, Password: password}, testClient, false)
+	if err != nil {
+		t.Fatalf("Login(%q) error = %v", email, err)
+	}
+	return token
+}
+
+// linkToken returns the token of the link in the last email
+func linkToken(t *testing.T, s *Services, box *outbox) string {
+	t.Helper()
+	s.Wait()
+	sent := box.messages()
+	if len(sent) == 0 {
+		t.Fatal("no email sent")
+	}
+	for _, field := range strings.Fields(sent[len(sent)-1].Body) {
+		if u, err := url.Parse(field); err == nil && u.Query().Has("token") {
+			return u.Query().Get("token")
+		}
+	}
+	t.Fatalf("no link in %q", sent[len(sent)-1].Body)
+	return ""
+}
+
+func TestRegister(t *testing.T) {
+	s, _ := newTestServices(t)
+	ctx := context.Background()
+	register(t, s, " Ann@Example.COM", testPassword)
+
+	if _, err := s.Register(ctx, models.User{Email: "Ann@example.com", Password: testPassword}); !errors.Is(err, ErrUserAlreadyExist) {
+		t.Errorf("Register() of the normalized email error = %v, want %v", err, ErrUserAlreadyExist)
+	}
+	var invalid *validation.Error
+	if _, err := s.Register(ctx, models.User{Email: "bob", Password: "short"}); !errors.As(err, &invalid) || len(invalid.Violations) != 2 {
+		t.Errorf("Register() of invalid input error = %v, want a violation of each field", err)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	s, _ := newTestServices(t)
+	id := register(t, s, testEmail, testPassword)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		err      error
+	}{
+		{name: "ok", email: testEmail, password: testPassword},
+		{name: "domain case ignored", email: " ann@EXAMPLE.com", password: testPassword},
+		{name: "wrong password", email: testEmail, password: "wrong horse", err: ErrIncorrectPassword},
+		{name: "unknown user", email: "bob@example.com", password: testPassword, err: ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := s.Login(ctx, models.User{Email: tt.email, Password: tt.password}, testClient, false)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Login() error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			claims, err := s.Authenticate(ctx, token.Access)
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			if claims.UserId != id || claims.SessionId == "" {
+				t.Errorf("claims = %+v, want user %d with a session", claims, id)
+			}
+			if !strings.HasPrefix(token.Refresh, jwt.RefreshTokenPrefix) {
+				t.Errorf("refresh token = %q, want the %q prefix", token.Refresh, jwt.RefreshTokenPrefix)
+			}
+		})
+	}
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	s, box := newTestServices(t, EmailVerification("https://app.example.com/verify", time.Hour, true))
+	register(t, s, testEmail, testPassword)
+	ctx := context.Background()
+
+	_, err := s.Login(ctx, models.User{Email: testEmail, Password: testPassword}, testClient, false)
+	if !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("Login() before verification error = %v, want %v", err, ErrEmailNotVerified)
+	}
+	if err := s.VerifyEmail(ctx, linkToken(t, s, box)); err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+	login(t, s, testEmail, testPassword)
+}
+
+func TestRefreshRotation(t *testing.T) {
+	s, _ := newTestServices(t)
+	id := register(t, s, testEmail, testPassword)
+	ctx := context.Background()
+	first := login(t, s, testEmail, testPassword)
+
+	second, err := s.RefreshSession(ctx, first.Refresh, testClient)
+	if err != nil {
+		t.Fatalf("RefreshSession() error = %v", err)
+	}
+	if second.Refresh == first.Refresh {
+		t.Fatal("RefreshSession() returned the same refresh token")
+	}
+	firstClaims, err := s.Authenticate(ctx, first.Access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondClaims, err := s.Authenticate(ctx, second.Access)
+	if err != nil {
+		t.Fatalf("Authenticate() of the rotated access token error = %v", err)
+	}
+	if secondClaims.SessionId != firstClaims.SessionId {
+		t.Errorf("session id changed on rotation: %q -> %q", firstClaims.SessionId, secondClaims.SessionId)
+	}
+	sessions, err := s.ListSessions(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].Id != firstClaims.SessionId {
+		t.Fatalf("sessions = %+v, want the one session %q", sessions, firstClaims.SessionId)
+	}
+	if session := sessions[0]; session.IP != testClient.IP || session.UserAgent != testClient.UserAgent || session.DeviceName != testClient.DeviceName {
+		t.Errorf("session = %+v, want the client %+v", session, testClient)
+	}
+
+	if _, err := s.RefreshSession(ctx, "rt_1.unknown", testClient); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RefreshSession() of an unknown token error = %v, want %v", err, ErrSessionNotFound)
+	}
+	if _, err := s.RefreshSession(ctx, "not a refresh token", testClient); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RefreshSession() of a malformed token error = %v, want %v", err, ErrSessionNotFound)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	s, _ := newTestServices(t)
+	register(t, s, testEmail, testPassword)
+	ctx := context.Background()
+	stolen := login(t, s, testEmail, testPassword)
+	other := login(t, s, testEmail, testPassword)
+
+	rotated, err := s.RefreshSession(ctx, stolen.Refresh, testClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the rotated token comes back, so the whole session is ended
+	if _, err := s.RefreshSession(ctx, stolen.Refresh, testClient); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("RefreshSession() of a spent token error = %v, want %v", err, ErrSessionNotFound)
+	}
+	if _, err := s.RefreshSession(ctx, rotated.Refresh, testClient); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RefreshSession() of the live token after reuse error = %v, want %v", err, ErrSessionNotFound)
+	}
+	if _, err := s.Authenticate(ctx, rotated.Access); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Authenticate() of the reused session error = %v, want %v", err, ErrTokenRevoked)
+	}
+
+	if _, err := s.Authenticate(ctx, other.Access); err != nil {
+		t.Errorf("Authenticate() of another session error = %v", err)
+	}
+	if _, err := s.RefreshSession(ctx, other.Refresh, testClient); err != nil {
+		t.Errorf("RefreshSession() of another session error = %v", err)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	s, _ := newTestServices(t)
+	register(t, s, testEmail, testPassword)
+	ctx := context.Background()
+	token := login(t, s, testEmail, testPassword)
+
+	if err := s.Logout(ctx, token.Refresh, token.Access); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if _, err := s.RefreshSession(ctx, token.Refresh, testClient); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RefreshSession() after logout error = %v, want %v", err, ErrSessionNotFound)
+	}
+	if _, err := s.Authenticate(ctx, token.Access); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Authenticate() after logout error = %v, want %v", err, ErrTokenRevoked)
+	}
+}
+
+// revokeOnGet revokes the session right after it is read, as a revocation racing the refresh would
+type revokeOnGet struct {
+	repository.RefreshSessionRepo
+}
+
+func (r revokeOnGet) GetSession(ctx context.Context, digest string) (models.RefreshSession, error) {
+	session, err := r.RefreshSessionRepo.GetSession(ctx, digest)
+	if err == nil {
+		err = r.RevokeSession(ctx, session.UserId, session.Id)
+	}
+	return session, err
+}
+
+func TestRefreshLosesToConcurrentRevoke(t *testing.T) {
+	s, _ := newTestServices(t)
+	id := register(t, s, testEmail, testPassword)
+	ctx := context.Background()
+	token := login(t, s, testEmail, testPassword)
+
+	sessions := s.repo.RefreshSession
+	s.repo.RefreshSession = revokeOnGet{sessions}
+	if _, err := s.RefreshSession(ctx, token.Refresh, testClient); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("RefreshSession() racing a revoke error = %v, want %v", err, ErrSessionNotFound)
+	}
+	s.repo.RefreshSession = sessions
+
+	list, err := s.ListSessions(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 0 {
+		t.Errorf("sessions = %+v, want the revoked session to stay gone", list)
+	}
+}
+
+var errStoreDown = errors.New("store down")
+
+// spentUnavailable fails every lookup of spent tokens
+type spentUnavailable struct {
+	repository.RefreshSessionRepo
+}
+
+func (r spentUnavailable) GetSpent(ctx context.Context, digest string) (models.RefreshSession, error) {
+	return models.RefreshSession{}, errStoreDown
+}
+
+// TestRefreshReportsReuseCheckFailure checks that a token that can't be checked for reuse
+// isn't reported as unknown: the client would drop it while a stolen copy stays live
+func TestRefreshReportsReuseCheckFailure(t *testing.T) {
+	s, _ := newTestServices(t)
+	register(t, s, testEmail, testPassword)
+	ctx := context.Background()
+	token := login(t, s, testEmail, testPassword)
+	if _, err := s.RefreshSession(ctx, token.Refresh, testClient); err != nil {
+		t.Fatal(err)
+	}
+
+	s.repo.RefreshSession = spentUnavailable{s.repo.RefreshSession}
+	_, err := s.RefreshSession(ctx, token.Refresh, testClient)
+	if !errors.Is(err, errStoreDown) {
+		t.Errorf("RefreshSession() of a spent token with the store down error = %v, want %v", err, errStoreDown)
+	}
+}
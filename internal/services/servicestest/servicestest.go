@@ -0,0 +1,114 @@
+// Package servicestest builds the services on the memory repositories for the tests of the controllers.
+package servicestest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/repository"
+	"github.com/d1mitrii/authentication-service/internal/repository/memory"
+	"github.com/d1mitrii/authentication-service/internal/services"
+	"github.com/d1mitrii/authentication-service/internal/services/jwt"
+	"github.com/d1mitrii/authentication-service/pkg/hasher"
+	"github.com/d1mitrii/authentication-service/pkg/mailer"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Issuer     = "https://auth.example.com"
+	AccessTTL  = time.Hour
+	RefreshTTL = 72 * time.Hour
+)
+
+// Outbox is a mailer that keeps the messages
+type Outbox struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (o *Outbox) Send(ctx context.Context, msg mailer.Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far
+func (o *Outbox) Messages() []mailer.Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]mailer.Message(nil), o.sent...)
+}
+
+// New builds the services with an Ed25519 signing key, verification and password reset links
+// are mailed to the outbox. opts are applied after the defaults.
+func New(t *testing.T, opts ...services.Option) (*services.Services, *Outbox) {
+	t.Helper()
+	keyring, err := jwt.NewKeyring(jwt.StaticKey("EdDSA", "", writeKey(t)), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := jwt.New(keyring, Issuer, []string{"api"}, AccessTTL, RefreshTTL)
+	repo := repository.New(
+		memory.NewUserRepo(),
+		memory.NewOneTimeTokenRepo(),
+		memory.NewRefreshRepo(RefreshTTL),
+		memory.NewTokenDenylist(),
+	)
+	box := &Outbox{}
+	opts = append([]services.Option{
+		services.Mailer(box),
+		services.EmailVerification("https://app.example.com/verify", time.Hour, false),
+		services.PasswordReset("https://app.example.com/reset", time.Hour),
+	}, opts...)
+	s := services.New(slog.New(slog.NewTextHandler(io.Discard, nil)), tokens, hasher.New(bcrypt.MinCost), repo, opts...)
+	t.Cleanup(s.Wait)
+	return s, box
+}
+
+func writeKey(t *testing.T) string {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// LinkToken returns the token of the link in the last email, once the services sent it
+func LinkToken(t *testing.T, s *services.Services, box *Outbox) string {
+	t.Helper()
+	s.Wait()
+	sent := box.Messages()
+	if len(sent) == 0 {
+		t.Fatal("no email sent")
+	}
+	for _, field := range strings.Fields(sent[len(sent)-1].Body) {
+		if u, err := url.Parse(field); err == nil && u.Query().Has("token") {
+			return u.Query().Get("token")
+		}
+	}
+	t.Fatalf("no link in %q", sent[len(sent)-1].Body)
+	return ""
+}
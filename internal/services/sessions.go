@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/metrics"
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
+)
+
+// ListSessions returns the live sessions of the user, most recently used first
+func (s *Services) ListSessions(ctx context.Context, userId int) ([]models.RefreshSession, error) {
+	const op = "Services.ListSessions"
+	sessions, err := s.repo.RefreshSession.ListSessions(ctx, userId)
+	if err != nil {
+		s.log.Error("failed to list sessions",
+			slog.String("operation", op),
+			slog.Int("user-id", userId),
+			slog.String("error", err.Error()),
+		)
+		return nil, err
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+// RevokeSession logs the user out of a single session, access tokens issued for the session stop working
+func (s *Services) RevokeSession(ctx context.Context, userId int, sessionId string) error {
+	const op = "Services.RevokeSession"
+	log := s.log.With(
+		slog.String("operation", op),
+		slog.Int("user-id", userId),
+		slog.String("session-id", sessionId),
+	)
+	if err := s.repo.RefreshSession.RevokeSession(ctx, userId, sessionId); err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			log.Warn(err.Error())
+			return ErrSessionNotFound
+		}
+		log.Error("failed to revoke session", slog.String("error", err.Error()))
+		return err
+	}
+	if err := s.revokeSessionTokens(ctx, log, sessionId); err != nil {
+		return err
+	}
+	log.Info("session revoked")
+	return nil
+}
+
+// RevokeAllSessions logs the user out everywhere, every access token of the user stops working
+func (s *Services) RevokeAllSessions(ctx context.Context, userId int) error {
+	const op = "Services.RevokeAllSessions"
+	log := s.log.With(
+		slog.String("operation", op),
+		slog.Int("user-id", userId),
+	)
+	if err := s.repo.RefreshSession.RevokeAllSessions(ctx, userId); err != nil {
+		log.Error("failed to revoke sessions", slog.String("error", err.Error()))
+		return err
+	}
+	if err := s.revokeUserTokens(ctx, log, userId); err != nil {
+		return err
+	}
+	log.Info("all sessions revoked")
+	return nil
+}
+
+// enforceSessionLimit makes room for a new session of the user according to the policy.
+// It is best effort: concurrent logins all count the same sessions and may exceed the limit,
+// the next login evicts the surplus (or is rejected until sessions end).
+func (s *Services) enforceSessionLimit(ctx context.Context, log *slog.Logger, userId int) error {
+	if s.maxSessions <= 0 {
+		return nil
+	}
+	sessions, err := s.repo.RefreshSession.ListSessions(ctx, userId)
+	if err != nil {
+		log.Error("failed to list sessions", slog.String("error", err.Error()))
+		return err
+	}
+	surplus := len(sessions) - s.maxSessions + 1
+	if surplus <= 0 {
+		return nil
+	}
+	if s.sessionPolicy == SessionLimitReject {
+		log.Info("session limit reached, login rejected", slog.Int("sessions", len(sessions)))
+		metrics.SessionsRejectedTotal()
+		return ErrSessionLimit
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if s.sessionPolicy == SessionLimitEvictLRU {
+			return sessions[i].LastUsedAt.Before(sessions[j].LastUsedAt)
+		}
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	for _, session := range sessions[:surplus] {
+		err := s.repo.RefreshSession.RevokeSession(ctx, userId, session.Id)
+		if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+			log.Error("failed to evict session", slog.String("error", err.Error()))
+			return err
+		}
+		if err := s.revokeSessionTokens(ctx, log, session.Id); err != nil {
+			return err
+		}
+		log.Info("session evicted by session limit",
+			slog.String("event", "session_evicted"),
+			slog.String("policy", string(s.sessionPolicy)),
+			slog.String("session-id", session.Id),
+			slog.Group("session-client",
+				slog.String("ip", session.IP),
+				slog.String("user-agent", session.UserAgent),
+			),
+		)
+	}
+	metrics.SessionsEvictedTotal(string(s.sessionPolicy), surplus)
+	return nil
+}
+
+func (s *Services) idle(session models.RefreshSession) time.Duration {
+	if session.Persistent && s.rememberMeTimeout > 0 {
+		return s.rememberMeTimeout
+	}
+	if s.idleTimeout > 0 {
+		return s.idleTimeout
+	}
+	return s.JWT.RefreshTTL()
+}
+
+// sessionExpiry is the end of the idle window, capped by the absolute lifetime of the session
+func (s *Services) sessionExpiry(session models.RefreshSession, now time.Time) time.Time {
+	expiresAt := now.Add(s.idle(session))
+	if s.maxLifetime > 0 {
+		if deadline := session.CreatedAt.Add(s.maxLifetime); deadline.Before(expiresAt) {
+			return deadline
+		}
+	}
+	return expiresAt
+}
+
+// sessionExpired checks the limits again at refresh time, the store expiring the session
+// is not relied on alone, and the current limits apply to sessions started before a config change
+func (s *Services) sessionExpired(session models.RefreshSession, now time.Time) bool {
+	if !now.Before(session.ExpiresAt) || now.Sub(session.LastUsedAt) >= s.idle(session) {
+		return true
+	}
+	return s.maxLifetime > 0 && now.Sub(session.CreatedAt) >= s.maxLifetime
+}
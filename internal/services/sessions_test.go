@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+)
+
+func TestSessionExpired(t *testing.T) {
+	login := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		idle       time.Duration
+		absolute   time.Duration
+		rememberMe time.Duration
+		persistent bool
+		lastUsed   time.Duration
+		now        time.Duration
+		want       bool
+	}{
+		{name: "fresh", idle: time.Hour, lastUsed: 0, now: time.Minute},
+		{name: "idle", idle: time.Hour, lastUsed: 0, now: time.Hour, want: true},
+		{name: "used recently", idle: time.Hour, lastUsed: 2 * time.Hour, now: 2*time.Hour + 59*time.Minute},
+		{name: "idle falls back to the refresh ttl", lastUsed: 0, now: 71 * time.Hour},
+		{name: "idle past the refresh ttl", lastUsed: 0, now: 72 * time.Hour, want: true},
+		{name: "absolute holds across use", idle: time.Hour, absolute: 8 * time.Hour, lastUsed: 7*time.Hour + 30*time.Minute, now: 8 * time.Hour, want: true},
+		{name: "before the absolute limit", idle: time.Hour, absolute: 8 * time.Hour, lastUsed: 7 * time.Hour, now: 7*time.Hour + 59*time.Minute},
+		{name: "remember me idles longer", idle: time.Hour, rememberMe: 24 * time.Hour, persistent: true, lastUsed: 0, now: 23 * time.Hour},
+		{name: "remember me idle", idle: time.Hour, rememberMe: 24 * time.Hour, persistent: true, lastUsed: 0, now: 24 * time.Hour, want: true},
+		{name: "remember me off", idle: time.Hour, persistent: true, lastUsed: 0, now: time.Hour, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServices(t, SessionTimeouts(tt.idle, tt.absolute), RememberMe(tt.rememberMe))
+			session := models.RefreshSession{
+				CreatedAt:  login,
+				LastUsedAt: login.Add(tt.lastUsed),
+				Persistent: tt.persistent,
+			}
+			session.ExpiresAt = s.sessionExpiry(session, session.LastUsedAt)
+			if got := s.sessionExpired(session, login.Add(tt.now)); got != tt.want {
+				t.Errorf("sessionExpired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionExpiryCappedByAbsoluteLimit(t *testing.T) {
+	s, _ := newTestServices(t, SessionTimeouts(time.Hour, 8*time.Hour))
+	login := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	session := models.RefreshSession{CreatedAt: login}
+	if got, want := s.sessionExpiry(session, login.Add(time.Hour)), login.Add(2*time.Hour); !got.Equal(want) {
+		t.Errorf("sessionExpiry() = %v, want %v", got, want)
+	}
+	if got, want := s.sessionExpiry(session, login.Add(7*time.Hour+30*time.Minute)), login.Add(8*time.Hour); !got.Equal(want) {
+		t.Errorf("sessionExpiry() = %v, want %v", got, want)
+	}
+}
+
+func TestRefreshAfterTimeouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		idle     time.Duration
+		absolute time.Duration
+		// time between the refreshes, shorter than the idle timeout in the absolute case
+		wait time.Duration
+	}{
+		{name: "idle", idle: time.Hour, wait: time.Hour},
+		{name: "absolute", idle: 3 * time.Hour, absolute: 4 * time.Hour, wait: 150 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newClock()
+			s, _ := newTestServicesAt(t, clock, SessionTimeouts(tt.idle, tt.absolute))
+			id := register(t, s, testEmail, testPassword)
+			ctx := context.Background()
+			token := login(t, s, testEmail, testPassword)
+
+			clock.advance(tt.wait - time.Minute)
+			token, err := s.RefreshSession(ctx, token.Refresh, testClient)
+			if err != nil {
+				t.Fatalf("RefreshSession() before the timeout error = %v", err)
+			}
+			clock.advance(tt.wait)
+			if _, err := s.RefreshSession(ctx, token.Refresh, testClient); !errors.Is(err, ErrSessionExpired) {
+				t.Fatalf("RefreshSession() after the timeout error = %v, want %v", err, ErrSessionExpired)
+			}
+			if list, err := s.ListSessions(ctx, id); err != nil || len(list) != 0 {
+				t.Errorf("sessions = %+v, %v, want the expired one ended", list, err)
+			}
+		})
+	}
+}
+
+func TestSessionLimit(t *testing.T) {
+	tests := []struct {
+		policy SessionLimitPolicy
+		// sessions that survive a third login, of first and second
+		// where the first one was refreshed after the second login
+		keepFirst, keepSecond bool
+		err                   error
+	}{
+		{policy: SessionLimitReject, keepFirst: true, keepSecond: true, err: ErrSessionLimit},
+		{policy: SessionLimitEvictOldest, keepSecond: true},
+		{policy: SessionLimitEvictLRU, keepFirst: true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			s, _ := newTestServices(t, SessionLimit(2, tt.policy))
+			id := register(t, s, testEmail, testPassword)
+			ctx := context.Background()
+
+			first := login(t, s, testEmail, testPassword)
+			second := login(t, s, testEmail, testPassword)
+			first, err := s.RefreshSession(ctx, first.Refresh, testClient)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = s.Login(ctx, models.User{Email: testEmail, Password: testPassword}, testClient, false)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("third Login() error = %v, want %v", err, tt.err)
+			}
+			sessions, err := s.ListSessions(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sessions) != 2 {
+				t.Errorf("%d sessions, want 2", len(sessions))
+			}
+
+			for _, c := range []struct {
+				name  string
+				token models.Token
+				keep  bool
+			}{{"first", first, tt.keepFirst}, {"second", second, tt.keepSecond}} {
+				_, err := s.Authenticate(ctx, c.token.Access)
+				if c.keep && err != nil {
+					t.Errorf("%s session: Authenticate() error = %v", c.name, err)
+				}
+				if !c.keep && !errors.Is(err, ErrTokenRevoked) {
+					t.Errorf("evicted %s session: Authenticate() error = %v, want %v", c.name, err, ErrTokenRevoked)
+				}
+				_, err = s.RefreshSession(ctx, c.token.Refresh, testClient)
+				if c.keep != (err == nil) {
+					t.Errorf("%s session: RefreshSession() error = %v, want kept %v", c.name, err, c.keep)
+				}
+			}
+		})
+	}
+}
+
+func TestRevokeSessions(t *testing.T) {
+	s, _ := newTestServices(t)
+	id := register(t, s, testEmail, testPassword)
+	ctx := context.Background()
+	first := login(t, s, testEmail, testPassword)
+	second := login(t, s, testEmail, testPassword)
+
+	claims, err := s.Authenticate(ctx, first.Access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RevokeSession(ctx, id, claims.SessionId); err != nil {
+		t.Fatalf("RevokeSession() error = %v", err)
+	}
+	if err := s.RevokeSession(ctx, id+1, claims.SessionId); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RevokeSession() of another user error = %v, want %v", err, ErrSessionNotFound)
+	}
+	if _, err := s.Authenticate(ctx, first.Access); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Authenticate() of the revoked session error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err := s.Authenticate(ctx, second.Access); err != nil {
+		t.Errorf("Authenticate() of the other session error = %v", err)
+	}
+	sessions, err := s.ListSessions(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 {
+		t.Errorf("sessions = %+v, want the one left", sessions)
+	}
+
+	if err := s.RevokeAllSessions(ctx, id); err != nil {
+		t.Fatalf("RevokeAllSessions() error = %v", err)
+	}
+	if _, err := s.Authenticate(ctx, second.Access); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Authenticate() after revoking all sessions error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err := s.RefreshSession(ctx, second.Refresh, testClient); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RefreshSession() after revoking all sessions error = %v, want %v", err, ErrSessionNotFound)
+	}
+	// a login right after still works
+	token := login(t, s, testEmail, testPassword)
+	if _, err := s.Authenticate(ctx, token.Access); err != nil {
+		t.Errorf("Authenticate() of a new login error = %v", err)
+	}
+}
+
+func TestRememberMe(t *testing.T) {
+	s, _ := newTestServices(t, SessionTimeouts(time.Hour, 0), RememberMe(24*time.Hour))
+	id := register(t, s, testEmail, testPassword)
+	ctx := context.Background()
+
+	short := login(t, s, testEmail, testPassword)
+	long, err := s.Login(ctx, models.User{Email: testEmail, Password: testPassword}, testClient, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if short.Persistent || !long.Persistent {
+		t.Errorf("persistent = %v and %v, want only the remember me login", short.Persistent, long.Persistent)
+	}
+	if got := long.RefreshExpiresAt.Sub(short.RefreshExpiresAt); got < 22*time.Hour {
+		t.Errorf("remember me session ends %v after the other, want about 23h", got)
+	}
+
+	// rotation keeps the session persistent
+	long, err = s.RefreshSession(ctx, long.Refresh, testClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !long.Persistent {
+		t.Error("refreshed remember me session is no longer persistent")
+	}
+	sessions, err := s.ListSessions(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	persistent := 0
+	for _, session := range sessions {
+		if session.Persistent {
+			persistent++
+		}
+	}
+	if len(sessions) != 2 || persistent != 1 {
+		t.Errorf("sessions = %+v, want one of the two persistent", sessions)
+	}
+}
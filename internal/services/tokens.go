@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
+	"github.com/d1mitrii/authentication-service/internal/services/jwt"
+)
+
+// Introspect reports whether an access or refresh token is active (RFC 7662).
+// The hint only changes the order in which token types are tried.
+func (s *Services) Introspect(ctx context.Context, token string, hint string) (models.Introspection, error) {
+	lookups := []func(context.Context, string) (models.Introspection, error){
+		s.introspectAccess,
+		s.introspectRefresh,
+	}
+	if hint == models.TokenTypeRefresh || strings.HasPrefix(token, jwt.RefreshTokenPrefix) {
+		slices.Reverse(lookups)
+	}
+	for _, lookup := range lookups {
+		result, err := lookup(ctx, token)
+		if err != nil || result.Active {
+			return result, err
+		}
+	}
+	return models.Introspection{}, nil
+}
+
+func (s *Services) introspectAccess(ctx context.Context, token string) (models.Introspection, error) {
+	claims, err := s.Authenticate(ctx, token)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return models.Introspection{}, nil
+		}
+		return models.Introspection{}, err
+	}
+	return models.Introspection{
+		Active:    true,
+		TokenType: models.TokenTypeAccess,
+		UserId:    claims.UserId,
+		Email:     claims.Email,
+		Scope:     strings.Join(claims.Roles, " "),
+		ID:        claims.ID,
+		Issuer:    claims.Issuer,
+		Audience:  claims.Audience,
+		IssuedAt:  claims.IssuedAt,
+		ExpiresAt: claims.ExpiresAt,
+	}, nil
+}
+
+func (s *Services) introspectRefresh(ctx context.Context, token string) (models.Introspection, error) {
+	const op = "Services.introspectRefresh"
+	session, err := s.repo.RefreshSession.GetSession(ctx, digest(token))
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return models.Introspection{}, nil
+		}
+		s.log.Error("failed to get refresh session",
+			slog.String("operation", op),
+			slog.String("error", err.Error()),
+		)
+		return models.Introspection{}, err
+	}
+	if s.sessionExpired(session, s.now()) {
+		return models.Introspection{}, nil
+	}
+	return models.Introspection{
+		Active:    true,
+		TokenType: models.TokenTypeRefresh,
+		UserId:    session.UserId,
+		IssuedAt:  session.LastUsedAt,
+		ExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+// Revoke invalidates an access or refresh token (RFC 7009).
+// Refresh tokens are recognized by their prefix, so the type hint isn't needed.
+// Unknown and invalid tokens are ignored, so callers can't probe which tokens exist.
+func (s *Services) Revoke(ctx context.Context, token string) error {
+	if strings.HasPrefix(token, jwt.RefreshTokenPrefix) {
+		return s.revokeRefresh(ctx, token)
+	}
+	return s.revokeAccess(ctx, token)
+}
+
+func (s *Services) revokeAccess(ctx context.Context, token string) error {
+	claims, err := s.JWT.Parse(token)
+	if err != nil {
+		return nil
+	}
+	return s.RevokeAccessToken(ctx, claims)
+}
+
+func (s *Services) revokeRefresh(ctx context.Context, token string) error {
+	const op = "Services.revokeRefresh"
+	tokenDigest := digest(token)
+	log := s.log.With(
+		slog.String("operation", op),
+		slog.String("refresh-digest", tokenDigest),
+	)
+	session, err := s.repo.RefreshSession.DeleteSession(ctx, tokenDigest)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return nil
+		}
+		log.Error("failed to get-delete refresh session", slog.String("error", err.Error()))
+		return err
+	}
+	err = s.repo.RefreshSession.RevokeSession(ctx, session.UserId, session.Id)
+	if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+		log.Error("failed to revoke session", slog.String("error", err.Error()))
+		return err
+	}
+	// access tokens of the same grant go too (RFC 7009 section 2.1)
+	if err := s.revokeSessionTokens(ctx, log, session.Id); err != nil {
+		return err
+	}
+	log.Info("refresh token revoked", slog.Int("user-id", session.UserId))
+	return nil
+}
+
+// rejectionReason maps a token parsing error to the reason the token is rejected
+func rejectionReason(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return ErrTokenMalformed
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return ErrTokenBadSignature
+	default:
+		return ErrTokenInvalidClaims
+	}
+}
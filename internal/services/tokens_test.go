@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+)
+
+func TestIntrospect(t *testing.T) {
+	s, _ := newTestServices(t)
+	id := register(t, s, testEmail, testPassword)
+	ctx := context.Background()
+	token := login(t, s, testEmail, testPassword)
+
+	tests := []struct {
+		name      string
+		token     string
+		hint      string
+		tokenType string
+	}{
+		{name: "access token", token: token.Access, tokenType: models.TokenTypeAccess},
+		{name: "access token with a wrong hint", token: token.Access, hint: models.TokenTypeRefresh, tokenType: models.TokenTypeAccess},
+		{name: "refresh token", token: token.Refresh, tokenType: models.TokenTypeRefresh},
+		{name: "refresh token with a wrong hint", token: token.Refresh, hint: models.TokenTypeAccess, tokenType: models.TokenTypeRefresh},
+		{name: "garbage", token: "garbage"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := s.Introspect(ctx, tt.token, tt.hint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Active != (tt.tokenType != "") || result.TokenType != tt.tokenType {
+				t.Fatalf("Introspect() = %+v, want an active %q token", result, tt.tokenType)
+			}
+			if result.Active && (result.UserId != id || result.ExpiresAt.IsZero()) {
+				t.Errorf("Introspect() = %+v, want user %d with an expiry", result, id)
+			}
+		})
+	}
+
+	if err := s.Logout(ctx, token.Refresh, token.Access); err != nil {
+		t.Fatal(err)
+	}
+	for _, revoked := range []string{token.Access, token.Refresh} {
+		if result, err := s.Introspect(ctx, revoked, ""); err != nil || result.Active {
+			t.Errorf("Introspect() after logout = %+v, %v, want inactive", result, err)
+		}
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	s, _ := newTestServices(t)
+	register(t, s, testEmail, testPassword)
+	ctx := context.Background()
+
+	for _, unknown := range []string{"garbage", "rt_unknown"} {
+		if err := s.Revoke(ctx, unknown); err != nil {
+			t.Errorf("Revoke(%q) error = %v, unknown tokens are ignored", unknown, err)
+		}
+	}
+
+	token := login(t, s, testEmail, testPassword)
+	other := login(t, s, testEmail, testPassword)
+	if err := s.Revoke(ctx, token.Access); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate(ctx, token.Access); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Authenticate() of a revoked access token error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err := s.RefreshSession(ctx, token.Refresh, testClient); err != nil {
+		t.Errorf("RefreshSession() after revoking the access token error = %v, the session stays", err)
+	}
+
+	// revoking a refresh token ends its session and the access tokens of the session
+	if err := s.Revoke(ctx, other.Refresh); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RefreshSession(ctx, other.Refresh, testClient); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RefreshSession() of a revoked token error = %v, want %v", err, ErrSessionNotFound)
+	}
+	if _, err := s.Authenticate(ctx, other.Access); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Authenticate() in a revoked session error = %v, want %v", err, ErrTokenRevoked)
+	}
+}
+
+func TestAuthenticateReasons(t *testing.T) {
+	s, _ := newTestServices(t)
+	register(t, s, testEmail, testPassword)
+	ctx := context.Background()
+	token := login(t, s, testEmail, testPassword)
+	if err := s.Revoke(ctx, token.Access); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{name: "malformed", token: "not a token", want: ErrTokenMalformed},
+		{name: "bad signature", token: token.Access[:len(token.Access)-2] + "xx", want: ErrTokenBadSignature},
+		{name: "revoked", token: token.Access, want: ErrTokenRevoked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Authenticate(ctx, tt.token)
+			if !errors.Is(err, tt.want) || !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Authenticate() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package validation
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+const (
+	maxEmailLength     = 254
+	maxLocalPartLength = 64
+)
+
+var domainProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.VerifyDNSLength(true),
+	idna.StrictDomainName(true),
+)
+
+// NormalizeEmail trims the address, checks its syntax and converts the domain to lower-case ASCII,
+// internationalized domains are stored in punycode.
+// The local part is kept as is, mail servers may treat it case-sensitively.
+func NormalizeEmail(email string) (string, error) {
+	var v violations
+	normalized := normalizeEmail(&v, email)
+	return normalized, v.err()
+}
+
+func normalizeEmail(v *violations, email string) string {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		v.add(FieldEmail, RuleRequired, "is required")
+		return ""
+	}
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		v.add(FieldEmail, RuleEmailFormat, "must contain @")
+		return email
+	}
+	local, domain := email[:at], strings.TrimSuffix(email[at+1:], ".")
+	if !validLocalPart(local) {
+		v.add(FieldEmail, RuleEmailFormat, "has an invalid local part")
+		return email
+	}
+	ascii, err := domainProfile.ToASCII(domain)
+	if err != nil || !strings.Contains(ascii, ".") {
+		v.add(FieldEmail, RuleEmailFormat, "has an invalid domain")
+		return email
+	}
+	email = local + "@" + ascii
+	if len(email) > maxEmailLength {
+		v.add(FieldEmail, RuleMaxLength, "must be at most %d characters", maxEmailLength)
+	}
+	return email
+}
+
+// validLocalPart accepts an unquoted dot-atom, UTF-8 is allowed as in RFC 6531
+func validLocalPart(local string) bool {
+	if local == "" || len(local) > maxLocalPartLength || !utf8.ValidString(local) {
+		return false
+	}
+	if local[0] == '.' || local[len(local)-1] == '.' || strings.Contains(local, "..") {
+		return false
+	}
+	for _, r := range local {
+		switch {
+		case r >= utf8.RuneSelf:
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		case strings.ContainsRune(".!#$%&'*+/=?^_`{|}~-", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		name  string
+		email string
+		want  string
+		rule  string
+	}{
+		{name: "trimmed", email: "  ann@example.com\t", want: "ann@example.com"},
+		{name: "domain lower-cased", email: "Ann@Example.COM", want: "Ann@example.com"},
+		{name: "trailing dot dropped", email: "ann@example.com.", want: "ann@example.com"},
+		{name: "unicode domain in punycode", email: "ann@Bücher.de", want: "ann@xn--bcher-kva.de"},
+		{name: "unicode local part", email: "jürgen@example.com", want: "jürgen@example.com"},
+		{name: "plus and dots", email: "ann.lee+tag@mail.example.com", want: "ann.lee+tag@mail.example.com"},
+		{name: "last at separates the domain", email: "a@b@example.com", rule: RuleEmailFormat},
+		{name: "empty", email: "   ", rule: RuleRequired},
+		{name: "no at", email: "ann.example.com", rule: RuleEmailFormat},
+		{name: "empty local part", email: "@example.com", rule: RuleEmailFormat},
+		{name: "leading dot", email: ".ann@example.com", rule: RuleEmailFormat},
+		{name: "double dot", email: "ann..lee@example.com", rule: RuleEmailFormat},
+		{name: "space in local part", email: "ann lee@example.com", rule: RuleEmailFormat},
+		{name: "local part too long", email: strings.Repeat("a", 65) + "@example.com", rule: RuleEmailFormat},
+		{name: "single label domain", email: "ann@localhost", rule: RuleEmailFormat},
+		{name: "invalid domain", email: "ann@exa_mple.com", rule: RuleEmailFormat},
+		{name: "too long", email: strings.Repeat("a", 64) + "@" + strings.Repeat(strings.Repeat("b", 62)+".", 3) + "com", rule: RuleMaxLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeEmail(tt.email)
+			if tt.rule == "" {
+				if err != nil {
+					t.Fatalf("NormalizeEmail(%q) error = %v", tt.email, err)
+				}
+				if got != tt.want {
+					t.Errorf("NormalizeEmail(%q) = %q, want %q", tt.email, got, tt.want)
+				}
+				return
+			}
+			var invalid *Error
+			if !errors.As(err, &invalid) {
+				t.Fatalf("NormalizeEmail(%q) error = %v, want *Error", tt.email, err)
+			}
+			if len(invalid.Violations) != 1 || invalid.Violations[0].Rule != tt.rule {
+				t.Errorf("NormalizeEmail(%q) violations = %+v, want rule %s", tt.email, invalid.Violations, tt.rule)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+)
+
+// bcrypt rejects longer passwords
+const maxPasswordBytes = 72
+
+// minEmailMatch is the shortest local part that passwords are checked against
+const minEmailMatch = 3
+
+// BreachChecker reports whether a password appears in known breaches
+type BreachChecker interface {
+	Breached(password string) (bool, error)
+}
+
+// PasswordPolicy is the set of rules new passwords must follow, zero values disable a rule.
+// Lengths are counted in characters.
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// Passwords containing any of the words are rejected, case is ignored
+	BannedWords []string
+	// Breached rejects passwords found in breach dumps, nil disables the check
+	Breached BreachChecker
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength: 8,
+		MaxLength: 72,
+	}
+}
+
+// ValidatePassword checks password against the policy,
+// email is the normalized address of the account the password belongs to
+func (p PasswordPolicy) ValidatePassword(password, email string) error {
+	var v violations
+	if err := p.validate(&v, password, email); err != nil {
+		return err
+	}
+	return v.err()
+}
+
+// validate collects the violations of password,
+// the error is returned only when the breach check itself fails
+func (p PasswordPolicy) validate(v *violations, password, email string) error {
+	if password == "" {
+		v.add(FieldPassword, RuleRequired, "is required")
+		return nil
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		v.add(FieldPassword, RuleMinLength, "must be at least %d characters", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		v.add(FieldPassword, RuleMaxLength, "must be at most %d characters", p.MaxLength)
+	} else if len(password) > maxPasswordBytes {
+		v.add(FieldPassword, RuleMaxLength, "must be at most %d bytes", maxPasswordBytes)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		v.add(FieldPassword, RuleUpper, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		v.add(FieldPassword, RuleLower, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		v.add(FieldPassword, RuleDigit, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		v.add(FieldPassword, RuleSymbol, "must contain a symbol")
+	}
+
+	lowered := strings.ToLower(password)
+	for _, word := range p.BannedWords {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" && strings.Contains(lowered, word) {
+			v.add(FieldPassword, RuleBannedWord, "must not contain common words")
+			break
+		}
+	}
+
+	if email != "" {
+		local, _, _ := strings.Cut(strings.ToLower(email), "@")
+		if lowered == strings.ToLower(email) || len(local) >= minEmailMatch && strings.Contains(lowered, local) {
+			v.add(FieldPassword, RuleContainsMail, "must not contain the email address")
+		}
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Breached(password)
+		if err != nil {
+			return fmt.Errorf("breach check: %w", err)
+		}
+		if breached {
+			v.add(FieldPassword, RuleBreached, "appears in a known data breach")
+		}
+	}
+	return nil
+}
+
+// Registration normalizes the email of a new user and checks the password against the policy,
+// every violation of both fields is reported in a single *Error
+func Registration(user models.User, policy PasswordPolicy) (models.User, error) {
+	var v violations
+	user.Email = normalizeEmail(&v, user.Email)
+	if err := policy.validate(&v, user.Password, user.Email); err != nil {
+		return user, err
+	}
+	return user, v.err()
+}
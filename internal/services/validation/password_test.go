@@ -0,0 +1,113 @@
+package validation
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/d1mitrii/authentication-service/internal/models"
+)
+
+type breachedSet map[string]bool
+
+func (b breachedSet) Breached(password string) (bool, error) {
+	return b[password], nil
+}
+
+type failingChecker struct{}
+
+func (failingChecker) Breached(string) (bool, error) {
+	return false, errors.New("corpus unavailable")
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	strict := PasswordPolicy{
+		MinLength:     10,
+		MaxLength:     20,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		BannedWords:   []string{"Secret", " "},
+		Breached:      breachedSet{"Tr0ub4dor&3x": true},
+	}
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		email    string
+		rules    []string
+	}{
+		{name: "default accepts a passphrase", policy: DefaultPasswordPolicy(), password: "correct horse"},
+		{name: "default too short", policy: DefaultPasswordPolicy(), password: "short", rules: []string{RuleMinLength}},
+		{name: "length counts characters", policy: DefaultPasswordPolicy(), password: "пароль12"},
+		{name: "over the bcrypt limit", policy: PasswordPolicy{}, password: strings.Repeat("ж", 40), rules: []string{RuleMaxLength}},
+		{name: "empty", policy: strict, password: "", rules: []string{RuleRequired}},
+		{name: "strict accepts", policy: strict, password: "Blue-Kettle-42"},
+		{name: "strict missing classes", policy: strict, password: "bluekettletea", rules: []string{RuleUpper, RuleDigit, RuleSymbol}},
+		{name: "strict too long", policy: strict, password: "Blue-Kettle-42-Blue-Kettle", rules: []string{RuleMaxLength}},
+		{name: "banned word ignores case", policy: strict, password: "My-SECRET-pass1", rules: []string{RuleBannedWord}},
+		{name: "breached", policy: strict, password: "Tr0ub4dor&3x", rules: []string{RuleBreached}},
+		{name: "contains the email", policy: strict, password: "Annie-Lee-2024", email: "annie@example.com", rules: []string{RuleContainsMail}},
+		{name: "short local part is not matched", policy: strict, password: "Blue-Kettle-42", email: "bl@example.com"},
+		{name: "equals the email", policy: DefaultPasswordPolicy(), password: "Al@Example.com", email: "al@example.com", rules: []string{RuleContainsMail}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.ValidatePassword(tt.password, tt.email)
+			if len(tt.rules) == 0 {
+				if err != nil {
+					t.Fatalf("ValidatePassword(%q) error = %v", tt.password, err)
+				}
+				return
+			}
+			var invalid *Error
+			if !errors.As(err, &invalid) {
+				t.Fatalf("ValidatePassword(%q) error = %v, want *Error", tt.password, err)
+			}
+			var rules []string
+			for _, v := range invalid.Violations {
+				if v.Field != FieldPassword {
+					t.Errorf("violation of field %q, want %q", v.Field, FieldPassword)
+				}
+				rules = append(rules, v.Rule)
+			}
+			if !slices.Equal(rules, tt.rules) {
+				t.Errorf("ValidatePassword(%q) rules = %v, want %v", tt.password, rules, tt.rules)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyBreachCheckFails(t *testing.T) {
+	policy := PasswordPolicy{Breached: failingChecker{}}
+	err := policy.ValidatePassword("correct horse", "")
+	var invalid *Error
+	if err == nil || errors.As(err, &invalid) {
+		t.Fatalf("ValidatePassword error = %v, want the checker error", err)
+	}
+}
+
+func TestRegistrationReportsEveryField(t *testing.T) {
+	user, err := Registration(models.User{Email: " Ann@Example.com ", Password: "ann"}, DefaultPasswordPolicy())
+	if user.Email != "Ann@example.com" {
+		t.Errorf("email = %q, want it normalized", user.Email)
+	}
+	var invalid *Error
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Registration error = %v, want *Error", err)
+	}
+	want := []Violation{
+		{Field: FieldPassword, Rule: RuleMinLength},
+		{Field: FieldPassword, Rule: RuleContainsMail},
+	}
+	if len(invalid.Violations) != len(want) {
+		t.Fatalf("violations = %+v, want %+v", invalid.Violations, want)
+	}
+	for i, v := range invalid.Violations {
+		if v.Field != want[i].Field || v.Rule != want[i].Rule {
+			t.Errorf("violation %d = %+v, want %+v", i, v, want[i])
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	FieldEmail    = "email"
+	FieldPassword = "password"
+)
+
+// Rules reported in violations
+const (
+	RuleRequired     = "required"
+	RuleEmailFormat  = "email_format"
+	RuleMinLength    = "min_length"
+	RuleMaxLength    = "max_length"
+	RuleUpper        = "uppercase"
+	RuleLower        = "lowercase"
+	RuleDigit        = "digit"
+	RuleSymbol       = "symbol"
+	RuleBannedWord   = "banned_word"
+	RuleContainsMail = "contains_email"
+	RuleBreached     = "breached"
+)
+
+// Violation describes one broken rule of one field
+type Violation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error lists every rule the input violates
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, fmt.Sprintf("%s: %s", v.Field, v.Message))
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+type violations []Violation
+
+func (v *violations) add(field, rule, format string, args ...any) {
+	*v = append(*v, Violation{Field: field, Rule: rule, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v violations) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return &Error{Violations: v}
+}
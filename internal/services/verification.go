@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/d1mitrii/authentication-service/internal/models"
+	"github.com/d1mitrii/authentication-service/internal/repository/repoerrors"
+	"github.com/d1mitrii/authentication-service/pkg/mailer"
+	"log/slog"
+	"net/url"
+	"time"
+)
+
+const defaultVerifyTTL = 24 * time.Hour
+
+// VerifyEmail consumes a verification token and marks the email of its user as verified
+func (s *Services) VerifyEmail(ctx context.Context, token string) error {
+	const op = "Services.VerifyEmail"
+	log := s.log.With(slog.String("operation", op))
+	stored, err := s.repo.OneTimeToken.ConsumeToken(ctx, models.PurposeEmailVerification, digest(token))
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			log.Info("unknown verification token")
+			return ErrInvalidVerificationToken
+		}
+		log.Error("failed to consume verification token", slog.String("error", err.Error()))
+		return err
+	}
+	log = log.With(slog.Int("user-id", stored.UserId))
+	if err := s.repo.User.MarkEmailVerified(ctx, stored.UserId, s.now()); err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			log.Warn("user not found")
+			return ErrInvalidVerificationToken
+		}
+		log.Error("failed to mark email verified", slog.String("error", err.Error()))
+		return err
+	}
+	log.Info("email verified")
+	return nil
+}
+
+// ResendVerification mails a new verification link, earlier links stop working.
+// The lookup and the email happen in the background and unknown or already verified emails are ignored,
+// so neither the answer nor its timing tells whether an account exists.
+func (s *Services) ResendVerification(ctx context.Context, email string) error {
+	const op = "Services.ResendVerification"
+	log := s.log.With(
+		slog.String("operation", op),
+		slog.String("email", email),
+	)
+	if s.mailer == nil {
+		log.Warn("no mailer configured, email verification is unavailable")
+		return nil
+	}
+	s.background(ctx, func(ctx context.Context) {
+		user, err := s.userByEmail(ctx, email)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				log.Info("user not found")
+				return
+			}
+			log.Error("failed to get user", slog.String("error", err.Error()))
+			return
+		}
+		if user.EmailVerified() {
+			log.Info("email already verified")
+			return
+		}
+		if err := s.sendVerification(ctx, user); err != nil {
+			log.Error("failed to send verification email", slog.String("error", err.Error()))
+		}
+	})
+	return nil
+}
+
+// sendVerification replaces the verification tokens of the user with a new one and mails it
+func (s *Services) sendVerification(ctx context.Context, user models.User) error {
+	if s.mailer == nil {
+		return nil
+	}
+	token, err := newOneTimeToken()
+	if err != nil {
+		return fmt.Errorf("newOneTimeToken: %v", err)
+	}
+	link, err := withToken(s.verifyLink, token)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.OneTimeToken.DeleteUserTokens(ctx, user.Id, models.PurposeEmailVerification); err != nil {
+		return err
+	}
+	now := s.now()
+	err = s.repo.OneTimeToken.CreateToken(ctx, digest(token), models.OneTimeToken{
+		Purpose:   models.PurposeEmailVerification,
+		UserId:    user.Id,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.verifyTTL),
+	})
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Confirm your email address by opening the link below:\n\n%s\n\n"+
+			"The link is valid for %s. If you didn't create an account, ignore this email.\n", link, s.verifyTTL),
+	})
+}
+
+// withToken appends the token to link as the "token" query parameter
+func withToken(link string, token string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("url.Parse: %v", err)
+	}
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+func newOneTimeToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestVerifyEmail(t *testing.T) {
+	s, box := newTestServices(t)
+	id := register(t, s, testEmail, testPassword)
+	ctx := context.Background()
+	token := linkToken(t, s, box)
+
+	if sent := box.messages(); sent[0].To != testEmail {
+		t.Errorf("verification sent to %q, want %q", sent[0].To, testEmail)
+	}
+	if err := s.VerifyEmail(ctx, "wrong"); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("VerifyEmail() of an unknown token error = %v, want %v", err, ErrInvalidVerificationToken)
+	}
+	if err := s.VerifyEmail(ctx, token); err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+	info, err := s.UserInfo(ctx, id)
+	if err != nil || !info.EmailVerified {
+		t.Errorf("UserInfo() = %+v, %v, want the email verified", info, err)
+	}
+	if err := s.VerifyEmail(ctx, token); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("VerifyEmail() of a used token error = %v, want %v", err, ErrInvalidVerificationToken)
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	s, box := newTestServices(t)
+	register(t, s, testEmail, testPassword)
+	ctx := context.Background()
+	first := linkToken(t, s, box)
+
+	if err := s.ResendVerification(ctx, " ann@EXAMPLE.com"); err != nil {
+		t.Fatal(err)
+	}
+	second := linkToken(t, s, box)
+	if first == second {
+		t.Fatal("the resent link carries the same token")
+	}
+	if err := s.VerifyEmail(ctx, first); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("VerifyEmail() of a replaced token error = %v, want %v", err, ErrInvalidVerificationToken)
+	}
+	if err := s.VerifyEmail(ctx, second); err != nil {
+		t.Fatalf("VerifyEmail() of the resent token error = %v", err)
+	}
+
+	// verified and unknown emails get the same answer and no email
+	for _, email := range []string{testEmail, "bob@example.com"} {
+		if err := s.ResendVerification(ctx, email); err != nil {
+			t.Errorf("ResendVerification(%q) error = %v", email, err)
+		}
+	}
+	s.Wait()
+	if sent := box.messages(); len(sent) != 2 {
+		t.Errorf("%d emails sent, want the two links only", len(sent))
+	}
+}
@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE refresh_sessions (
+    digest CHAR(64) PRIMARY KEY,
+    session_id TEXT NOT NULL UNIQUE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL,
+    last_used_at TIMESTAMPTZ NOT NULL,
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    device_name TEXT NOT NULL DEFAULT '',
+    persistent BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX refresh_sessions_user_id_idx ON refresh_sessions (user_id);
+CREATE INDEX refresh_sessions_expires_at_idx ON refresh_sessions (expires_at);
+
+CREATE TABLE refresh_spent (
+    digest CHAR(64) PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX refresh_spent_expires_at_idx ON refresh_spent (expires_at);
+
+CREATE TABLE token_denylist (
+    jti TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX token_denylist_expires_at_idx ON token_denylist (expires_at);
+
+CREATE TABLE token_revocations (
+    subject TEXT PRIMARY KEY,
+    revoked_before TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX token_revocations_expires_at_idx ON token_revocations (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE token_revocations;
+DROP TABLE token_denylist;
+DROP TABLE refresh_spent;
+DROP TABLE refresh_sessions;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ;
+-- users that signed up before verification existed keep logging in
+UPDATE users SET email_verified_at = NOW();
+
+CREATE TABLE one_time_tokens (
+    digest CHAR(64) PRIMARY KEY,
+    purpose TEXT NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX one_time_tokens_user_id_idx ON one_time_tokens (user_id, purpose);
+CREATE INDEX one_time_tokens_expires_at_idx ON one_time_tokens (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE one_time_tokens;
+ALTER TABLE users DROP COLUMN email_verified_at;
+-- +goose StatementEnd
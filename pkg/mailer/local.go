@@ -0,0 +1,62 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// Log writes messages to the log instead of sending them, for local development.
+// Bodies carry live links, so they are only logged at debug level.
+type Log struct {
+	log *slog.Logger
+}
+
+func NewLog(log *slog.Logger) *Log {
+	return &Log{log: log}
+}
+
+func (l *Log) Send(ctx context.Context, msg Message) error {
+	l.log.Info("mail",
+		slog.String("to", msg.To),
+		slog.String("subject", msg.Subject),
+	)
+	l.log.Debug("mail body",
+		slog.String("to", msg.To),
+		slog.String("body", msg.Body),
+	)
+	return nil
+}
+
+// File appends messages to a file in RFC 5322 format instead of sending them,
+// for local development and tests
+type File struct {
+	mu   sync.Mutex
+	path string
+	from string
+}
+
+func NewFile(path, from string) *File {
+	return &File{path: path, from: from}
+}
+
+func (f *File) Send(ctx context.Context, msg Message) error {
+	const op = "mailer.File.Send"
+	data, err := format(f.from, msg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("%s - os.OpenFile: %w", op, err)
+	}
+	if _, err := file.Write(append(data, "\r\n"...)); err != nil {
+		file.Close()
+		return fmt.Errorf("%s - file.Write: %w", op, err)
+	}
+	return file.Close()
+}
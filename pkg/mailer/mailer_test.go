@@ -0,0 +1,177 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	msg := Message{
+		To:      "ann@example.com",
+		Subject: "Vérifiez votre adresse",
+		Body:    "Open the link:\nhttps://app.example.com/verify?token=abc",
+	}
+	data, err := format("auth@example.com", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("mail.ReadMessage() error = %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != msg.Subject {
+		t.Errorf("Subject = %q, %v, want %q", subject, err, msg.Subject)
+	}
+	if from := parsed.Header.Get("From"); from != "auth@example.com" {
+		t.Errorf("From = %q", from)
+	}
+	if _, err := parsed.Header.Date(); err != nil {
+		t.Errorf("Date header error = %v", err)
+	}
+	body := string(data[strings.Index(string(data), "\r\n\r\n")+4:])
+	if want := "Open the link:\r\nhttps://app.example.com/verify?token=abc\r\n"; body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestFormatRejectsLineBreaks(t *testing.T) {
+	tests := []Message{
+		{To: "ann@example.com\r\nBcc: eve@example.com", Subject: "hi"},
+		{To: "ann@example.com", Subject: "hi\nBcc: eve@example.com"},
+	}
+	for _, msg := range tests {
+		if _, err := format("auth@example.com", msg); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("format(%q, %q) error = %v, want %v", msg.To, msg.Subject, err, ErrInvalidHeader)
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.txt")
+	f := NewFile(path, "auth@example.com")
+	for _, to := range []string{"ann@example.com", "bob@example.com"} {
+		if err := f.Send(context.Background(), Message{To: to, Subject: "hi", Body: "hello"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "To: "); n != 2 {
+		t.Errorf("file holds %d messages, want 2", n)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("file mode = %v, want 0600", perm)
+	}
+}
+
+// smtpServer is a relay without STARTTLS that accepts one connection and keeps what it was sent
+type smtpServer struct {
+	listener net.Listener
+	commands chan string
+	data     chan string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{
+		listener: listener,
+		commands: make(chan string, 16),
+		data:     make(chan string, 1),
+	}
+	t.Cleanup(func() { listener.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, _, _ := strings.Cut(line, " ")
+		s.commands <- strings.ToUpper(verb)
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			text.PrintfLine("250-localhost")
+			text.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			text.PrintfLine("235 authenticated")
+		case "MAIL", "RCPT":
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			data, err := io.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			s.data <- string(data)
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	server := newSMTPServer(t)
+	s := NewSMTP("127.0.0.1", server.port(), "user", "secret", "auth@example.com", TLS(TLSNone))
+	if err := s.Send(context.Background(), Message{To: "ann@example.com", Subject: "hi", Body: "hello"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	// the relay reads every command before it answers, QUIT included
+	var commands []string
+	for len(server.commands) != 0 {
+		commands = append(commands, <-server.commands)
+	}
+	if got, want := strings.Join(commands, " "), "EHLO AUTH MAIL RCPT DATA QUIT"; got != want {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+	data := <-server.data
+	if !strings.Contains(data, "To: ann@example.com\n") || !strings.HasSuffix(data, "hello\n") {
+		t.Errorf("data = %q", data)
+	}
+}
+
+func TestSMTPRequiresStartTLS(t *testing.T) {
+	server := newSMTPServer(t)
+	s := NewSMTP("127.0.0.1", server.port(), "", "", "auth@example.com")
+	err := s.Send(context.Background(), Message{To: "ann@example.com", Subject: "hi", Body: "hello"})
+	if !errors.Is(err, ErrStartTLSUnsupported) {
+		t.Errorf("Send() error = %v, want %v", err, ErrStartTLSUnsupported)
+	}
+}
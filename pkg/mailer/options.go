@@ -0,0 +1,19 @@
+package mailer
+
+import "time"
+
+type Option func(*SMTP)
+
+// Timeout limits the whole SMTP exchange of one message
+func Timeout(timeout time.Duration) Option {
+	return func(s *SMTP) {
+		s.timeout = timeout
+	}
+}
+
+// TLS sets how the connection to the relay is encrypted, TLSStartTLS by default
+func TLS(mode TLSMode) Option {
+	return func(s *SMTP) {
+		s.tlsMode = mode
+	}
+}
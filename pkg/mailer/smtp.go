@@ -0,0 +1,118 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+const _defaultTimeout = 10 * time.Second
+
+// TLSMode is how the connection to the relay is encrypted
+type TLSMode string
+
+const (
+	// TLSStartTLS upgrades the connection with STARTTLS and fails when the server doesn't offer it
+	TLSStartTLS TLSMode = "starttls"
+	// TLSImplicit speaks TLS from the start, usually on port 465
+	TLSImplicit TLSMode = "implicit"
+	// TLSNone sends mail in plain text, only for relays on a trusted network
+	TLSNone TLSMode = "none"
+)
+
+var ErrStartTLSUnsupported = errors.New("mailer: server doesn't support STARTTLS")
+
+// SMTP sends mail through a relay over TLS, STARTTLS by default.
+// Credentials are only sent over TLS or to localhost.
+type SMTP struct {
+	host     string
+	addr     string
+	username string
+	password string
+	from     string
+	timeout  time.Duration
+	tlsMode  TLSMode
+}
+
+func NewSMTP(host string, port int, username, password, from string, opts ...Option) *SMTP {
+	s := &SMTP{
+		host:     host,
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		username: username,
+		password: password,
+		from:     from,
+		timeout:  _defaultTimeout,
+		tlsMode:  TLSStartTLS,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	const op = "mailer.SMTP.Send"
+	data, err := format(s.from, msg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	tlsConfig := &tls.Config{ServerName: s.host, MinVersion: tls.VersionTLS12}
+	var dialer interface {
+		DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+	} = &net.Dialer{}
+	if s.tlsMode == TLSImplicit {
+		dialer = &tls.Dialer{Config: tlsConfig}
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("%s - dialer.DialContext: %w", op, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return fmt.Errorf("%s - smtp.NewClient: %w", op, err)
+	}
+	defer client.Close()
+	if s.tlsMode == TLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s: %w", op, ErrStartTLSUnsupported)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("%s - client.StartTLS: %w", op, err)
+		}
+	}
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("%s - client.Auth: %w", op, err)
+		}
+	}
+	if err := client.Mail(s.from); err != nil {
+		return fmt.Errorf("%s - client.Mail: %w", op, err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("%s - client.Rcpt: %w", op, err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("%s - client.Data: %w", op, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("%s - w.Write: %w", op, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("%s - w.Close: %w", op, err)
+	}
+	return client.Quit()
+}
@@ -0,0 +1,81 @@
+package realip
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// Proxies are the reverse proxies whose X-Real-IP and X-Forwarded-For headers are believed.
+// Anyone else can set the headers to anything, so for other peers the peer address is the client.
+type Proxies struct {
+	prefixes []netip.Prefix
+}
+
+// New takes the addresses or CIDR ranges of the proxies, none means no proxy is trusted
+func New(proxies []string) (*Proxies, error) {
+	p := &Proxies{}
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			p.prefixes = append(p.prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+		}
+		p.prefixes = append(p.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return p, nil
+}
+
+// ClientIP returns the address of the client that reached peer, a "host:port" or a bare address.
+// Behind a trusted proxy X-Real-IP wins, then X-Forwarded-For is read from the right
+// and the first hop that isn't a trusted proxy is the client.
+func (p *Proxies) ClientIP(peer string, realIP string, forwardedFor []string) string {
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	if !p.trusts(peer) {
+		return peer
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(realIP)); err == nil {
+		return addr.Unmap().String()
+	}
+
+	var hops []string
+	for _, value := range forwardedFor {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = addr.Unmap().String()
+		if !p.trusts(client) {
+			break
+		}
+	}
+	return client
+}
+
+func (p *Proxies) trusts(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
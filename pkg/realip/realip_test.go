@@ -0,0 +1,56 @@
+package realip
+
+import "testing"
+
+func TestClientIP(t *testing.T) {
+	proxies, err := New([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		peer         string
+		realIP       string
+		forwardedFor []string
+		want         string
+	}{
+		{"direct client", "198.51.100.7:5000", "", nil, "198.51.100.7"},
+		{"untrusted peer sets headers", "198.51.100.7:5000", "203.0.113.9", []string{"203.0.113.9"}, "198.51.100.7"},
+		{"trusted proxy with real ip", "10.1.2.3:5000", "203.0.113.9", nil, "203.0.113.9"},
+		{"trusted single address", "192.0.2.1:5000", "203.0.113.9", nil, "203.0.113.9"},
+		{"trusted proxy with forwarded for", "10.1.2.3:5000", "", []string{"203.0.113.9"}, "203.0.113.9"},
+		{"spoofed hop left of the client", "10.1.2.3:5000", "", []string{"1.1.1.1, 203.0.113.9"}, "203.0.113.9"},
+		{"chain of trusted proxies", "10.1.2.3:5000", "", []string{"203.0.113.9, 10.9.9.9", "10.8.8.8"}, "203.0.113.9"},
+		{"only trusted hops", "10.1.2.3:5000", "", []string{"10.9.9.9"}, "10.9.9.9"},
+		{"garbage hop", "10.1.2.3:5000", "", []string{"203.0.113.9, nonsense"}, "10.1.2.3"},
+		{"garbage real ip", "10.1.2.3:5000", "nonsense", []string{"203.0.113.9"}, "203.0.113.9"},
+		{"peer without port", "198.51.100.7", "", nil, "198.51.100.7"},
+		{"ipv6 peer", "[2001:db8::1]:5000", "203.0.113.9", nil, "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := proxies.ClientIP(tt.peer, tt.realIP, tt.forwardedFor); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoTrustedProxies(t *testing.T) {
+	proxies, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := proxies.ClientIP("127.0.0.1:5000", "203.0.113.9", []string{"203.0.113.9"}); got != "127.0.0.1" {
+		t.Errorf("ClientIP() = %q, want the peer address", got)
+	}
+}
+
+func TestNewRejectsInvalidProxies(t *testing.T) {
+	if _, err := New([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("New() accepted an invalid range")
+	}
+	if _, err := New([]string{"proxy.local"}); err == nil {
+		t.Error("New() accepted a host name")
+	}
+}
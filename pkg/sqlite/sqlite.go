@@ -0,0 +1,62 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const (
+	_defaultBusyTimeout = 5 * time.Second
+)
+
+// SQLite is a database file opened in WAL mode, readers don't block the writer
+// and concurrent writers wait for the lock up to the busy timeout.
+// The driver is pure Go, the binary builds with CGO_ENABLED=0.
+type SQLite struct {
+	busyTimeout time.Duration
+
+	DB *sql.DB
+}
+
+func New(path string, opts ...Option) (*SQLite, error) {
+	s := &SQLite{
+		busyTimeout: _defaultBusyTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// busy_timeout goes first, switching to WAL already needs the lock
+	params := url.Values{}
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", s.busyTimeout.Milliseconds()))
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "synchronous(NORMAL)")
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Set("_txlock", "immediate")
+	// Times are written as "2006-01-02 15:04:05.999999999-07:00", they compare as text in UTC
+	params.Set("_time_format", "sqlite")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("sqlite - New - sql.Open: %w", err)
+	}
+	if err := db.PingContext(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite - New - db.Ping: %w", err)
+	}
+	s.DB = db
+
+	return s, nil
+}
+
+func (s *SQLite) Close() {
+	if s.DB != nil {
+		s.DB.Close()
+	}
+}